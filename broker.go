@@ -1,6 +1,9 @@
 package beacon
 
-import "errors"
+import (
+	"errors"
+	"reflect"
+)
 
 var (
 	ErrNoSubscriber = errors.New("broker does not have a subscriber associated")
@@ -10,12 +13,30 @@ var (
 type Broker struct {
 	subscriber Subscriber
 	publisher  Publisher
+
+	// Prefix of the topics on which replies to requests are received.
+	replyPrefix string
+}
+
+type BrokerOption func(*Broker)
+
+func NewBroker(subscriber Subscriber, publisher Publisher, options ...BrokerOption) *Broker {
+	b := &Broker{
+		subscriber:  subscriber,
+		publisher:   publisher,
+		replyPrefix: DefaultReplyPrefix,
+	}
+
+	for _, opt := range options {
+		opt(b)
+	}
+
+	return b
 }
 
-func NewBroker(subscriber Subscriber, publisher Publisher) *Broker {
-	return &Broker{
-		subscriber: subscriber,
-		publisher:  publisher,
+func WithReplyPrefix(prefix string) func(*Broker) {
+	return func(b *Broker) {
+		b.replyPrefix = prefix
 	}
 }
 
@@ -51,33 +72,75 @@ func (b *Broker) Disconnect() error {
 	return nil
 }
 
-func (b *Broker) Subscribe(topic *Topic) (<-chan RoutedMessage, error) {
+// OnConnectionStateChange registers the handler on both the subscriber and the publisher. When both are
+// the same transport, the handler is registered only once.
+func (b *Broker) OnConnectionStateChange(handler ConnectionStateHandler) {
+	if b.subscriber != nil {
+		b.subscriber.OnConnectionStateChange(handler)
+	}
+
+	if b.publisher != nil && !b.sharesConnection() {
+		b.publisher.OnConnectionStateChange(handler)
+	}
+}
+
+// sharesConnection reports whether the subscriber and the publisher are the same transport.
+func (b *Broker) sharesConnection() bool {
+	if b.subscriber == nil || b.publisher == nil {
+		return false
+	}
+
+	// Comparing interfaces holding the same incomparable type panics.
+	subscriberType := reflect.TypeOf(b.subscriber)
+	if subscriberType != reflect.TypeOf(b.publisher) || !subscriberType.Comparable() {
+		return false
+	}
+
+	return Connector(b.subscriber) == Connector(b.publisher)
+}
+
+func (b *Broker) Subscribe(topic *Topic, options ...SubscribeOption) (<-chan RoutedMessage, error) {
 	if b.subscriber == nil {
 		return nil, ErrNoSubscriber
 	}
 
-	return b.subscriber.Subscribe(topic)
+	return b.subscriber.Subscribe(topic, options...)
 }
 
-func (b *Broker) Publish(topic *Topic, message Message) error {
+func (b *Broker) Unsubscribe(topic *Topic) error {
+	if b.subscriber == nil {
+		return ErrNoSubscriber
+	}
+
+	return b.subscriber.Unsubscribe(topic)
+}
+
+func (b *Broker) Publish(topic *Topic, message Message, options ...PublishOption) error {
 	if b.publisher == nil {
 		return ErrNoPublisher
 	}
 
-	return b.publisher.Publish(topic, message)
+	return b.publisher.Publish(topic, message, options...)
 }
 
 type Connector interface {
 	Connect() error
 	Disconnect() error
+
+	// OnConnectionStateChange registers a handler called whenever the state of the connection changes.
+	OnConnectionStateChange(handler ConnectionStateHandler)
 }
 
 type Subscriber interface {
 	Connector
-	Subscribe(topic *Topic) (<-chan RoutedMessage, error)
+	Subscribe(topic *Topic, options ...SubscribeOption) (<-chan RoutedMessage, error)
+
+	// Unsubscribe ends the subscription made by calling Subscribe with topic. Subscriptions are
+	// identified by the topic instance, so other subscriptions to an equal topic are kept.
+	Unsubscribe(topic *Topic) error
 }
 
 type Publisher interface {
 	Connector
-	Publish(topic *Topic, message Message) error
+	Publish(topic *Topic, message Message, options ...PublishOption) error
 }
@@ -1,41 +1,332 @@
 package brokers
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pmoura-dev/beacon"
 )
 
+var (
+	ErrLocalBrokerNotConnected  = errors.New("local broker is not connected")
+	ErrInvalidLocalBrokerOption = errors.New("local broker option is invalid")
+)
+
 type LocalBroker struct {
-	subscriptions map[string][]chan beacon.Message
+	subscriptions *beacon.TopicTrie[*localSubscription]
+	bufferSize    int
+	optionsErr    error // error validating the options, returned by Connect
+
+	// Last retained message of each concrete topic. Retained messages survive reconnections.
+	retained map[string]beacon.Message
+
+	// Number of messages delivered to each consumer group, used to deliver them round-robin.
+	groupDeliveries map[localGroup]*atomic.Uint64
+
+	mu        sync.RWMutex
+	connected bool
+
+	stateHandlers   []beacon.ConnectionStateHandler
+	stateHandlersMu sync.Mutex
+}
 
-	mu     sync.Mutex
-	closed bool
+type localSubscription struct {
+	messageChan chan beacon.RoutedMessage
+
+	// Consumer group sharing the subscription, or nil if it is not shared.
+	group *localGroup
+
+	// Closed when the subscription ends, to release publishers blocked on its full channel.
+	done chan struct{}
+
+	// Held by publishers while sending, so that the message channel is not closed under them.
+	sendMu sync.RWMutex
 }
 
-func NewLocalBroker() *LocalBroker {
-	return &LocalBroker{
-		subscriptions: make(map[string][]chan beacon.Message),
+func newLocalSubscription(bufferSize int) *localSubscription {
+	return &localSubscription{
+		messageChan: make(chan beacon.RoutedMessage, bufferSize),
+		done:        make(chan struct{}),
 	}
 }
 
-func (b *LocalBroker) Subscribe(topic string) (<-chan beacon.Message, error) {
+// send blocks until the message is delivered or the subscription ends.
+func (s *localSubscription) send(message beacon.RoutedMessage) {
+	s.sendMu.RLock()
+	defer s.sendMu.RUnlock()
+
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	select {
+	case s.messageChan <- message:
+	case <-s.done:
+	}
+}
+
+// close ends the subscription. It must be called once, after removing it from the broker.
+func (s *localSubscription) close() {
+	close(s.done)
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	close(s.messageChan)
+}
+
+// localGroup identifies the subscriptions of a consumer group to the same topic.
+type localGroup struct {
+	topic string
+	name  string
+}
+
+type LocalBrokerOption func(*LocalBroker)
+
+func NewLocalBroker(options ...LocalBrokerOption) *LocalBroker {
+	broker := &LocalBroker{
+		subscriptions:   beacon.NewTopicTrie[*localSubscription](),
+		bufferSize:      64,
+		retained:        make(map[string]beacon.Message),
+		groupDeliveries: make(map[localGroup]*atomic.Uint64),
+	}
+
+	for _, opt := range options {
+		opt(broker)
+	}
+
+	if broker.bufferSize < 0 {
+		broker.optionsErr = fmt.Errorf("%w: buffer size %d is negative", ErrInvalidLocalBrokerOption, broker.bufferSize)
+	}
+
+	return broker
+}
+
+func WithBufferSize(size int) func(*LocalBroker) {
+	return func(b *LocalBroker) {
+		b.bufferSize = size
+	}
+}
+
+func (b *LocalBroker) Connect() error {
+	if b.optionsErr != nil {
+		return b.optionsErr
+	}
+
+	b.mu.Lock()
+
+	if b.connected {
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.connected = true
+	b.mu.Unlock()
+
+	// The local broker can not lose its connection, so connecting is the only state change reported.
+	b.stateHandlersMu.Lock()
+	handlers := slices.Clone(b.stateHandlers)
+	b.stateHandlersMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(beacon.ConnectionEvent{State: beacon.ConnectionConnected})
+	}
+
+	return nil
+}
+
+func (b *LocalBroker) Disconnect() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if b.closed {
-		return nil, nil
+	if !b.connected {
+		return nil
 	}
 
-	messageChan := make(chan beacon.Message)
-	b.subscriptions[topic] = append(b.subscriptions[topic], messageChan)
-	return messageChan, nil
+	for _, sub := range b.subscriptions.Values() {
+		sub.close()
+	}
+
+	b.subscriptions = beacon.NewTopicTrie[*localSubscription]()
+	b.connected = false
+	return nil
+}
+
+func (b *LocalBroker) OnConnectionStateChange(handler beacon.ConnectionStateHandler) {
+	b.stateHandlersMu.Lock()
+	defer b.stateHandlersMu.Unlock()
+
+	b.stateHandlers = append(b.stateHandlers, handler)
 }
 
-func (b *LocalBroker) Publish(topic string, message beacon.Message) {
-	for _, ch := range b.subscriptions[topic] {
-		ch <- message
+func (b *LocalBroker) Subscribe(topic *beacon.Topic, options ...beacon.SubscribeOption) (<-chan beacon.RoutedMessage, error) {
+	opts, err := beacon.NewSubscribeOptions(options...)
+	if err != nil {
+		return nil, err
 	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.connected {
+		return nil, ErrLocalBrokerNotConnected
+	}
+
+	sub := newLocalSubscription(b.bufferSize)
+
+	if opts.Group != "" {
+		sub.group = &localGroup{topic: topic.Raw(), name: opts.Group}
+		if _, ok := b.groupDeliveries[*sub.group]; !ok {
+			b.groupDeliveries[*sub.group] = &atomic.Uint64{}
+		}
+	}
+
+	b.subscriptions.Insert(topic, sub)
+
+	// As in MQTT, retained messages are not delivered to shared subscriptions.
+	if sub.group != nil {
+		return sub.messageChan, nil
+	}
+
+	// Retained messages are delivered without blocking, so those exceeding the buffer are dropped.
+	for rawTopic, message := range b.retained {
+		match, ok := topic.Match(rawTopic)
+		if !ok {
+			continue
+		}
+
+		routed := beacon.RoutedMessage{Message: message, Topic: match}
+		routed.Headers = maps.Clone(message.Headers)
+
+		select {
+		case sub.messageChan <- routed:
+		default:
+		}
+	}
+
+	return sub.messageChan, nil
+}
+
+func (b *LocalBroker) Unsubscribe(topic *beacon.Topic) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.connected {
+		return ErrLocalBrokerNotConnected
+	}
+
+	for _, sub := range b.subscriptions.Remove(topic) {
+		sub.close()
+	}
+
+	return nil
+}
+
+func (b *LocalBroker) Publish(topic *beacon.Topic, message beacon.Message, options ...beacon.PublishOption) error {
+	opts, err := beacon.NewPublishOptions(options...)
+	if err != nil {
+		return err
+	}
+
+	// Messages are delivered in memory as soon as they are published, which satisfies any QoS, but they
+	// are neither expired nor reordered.
+	if opts.Expiry != 0 {
+		return fmt.Errorf("%w: local broker does not support message expiry", beacon.ErrUnsupportedPublishOption)
+	}
+	if opts.Priority != nil {
+		return fmt.Errorf("%w: local broker does not support message priority", beacon.ErrUnsupportedPublishOption)
+	}
+
+	if topic.HasWildcards() {
+		return beacon.ErrWildcardTopic
+	}
+
+	recipients, err := b.recipients(topic, message, opts.Retain)
+	if err != nil {
+		return err
+	}
+
+	// Messages are sent without holding the lock, since sending blocks while a subscription is full and
+	// its handler may need the lock to publish or to change subscriptions.
+	for _, recipient := range recipients {
+		recipient.sub.send(recipient.message)
+	}
+
+	return nil
 }
 
-func (b *LocalBroker) Close() {}
+type localRecipient struct {
+	sub     *localSubscription
+	message beacon.RoutedMessage
+}
+
+// recipients returns the subscriptions a message published to topic is delivered to, retaining the
+// message first if requested.
+func (b *LocalBroker) recipients(topic *beacon.Topic, message beacon.Message, retain bool) ([]localRecipient, error) {
+	// Retaining modifies the broker state, so it requires the write lock.
+	if retain {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+	} else {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+	}
+
+	if !b.connected {
+		return nil, ErrLocalBrokerNotConnected
+	}
+
+	// As in MQTT, retaining an empty payload clears the retained message of the topic.
+	if retain {
+		if len(message.Payload) == 0 {
+			delete(b.retained, topic.Raw())
+		} else {
+			retained := message
+			retained.Headers = maps.Clone(message.Headers)
+			b.retained[topic.Raw()] = retained
+		}
+	}
+
+	// Each consumer group receives the message once, on one of its members in turn, so the members of a
+	// group are collected and the group is delivered to at the position of its first member.
+	var matches []beacon.TrieMatch[*localSubscription]
+	groups := make(map[localGroup][]*localSubscription)
+	for _, match := range b.subscriptions.Match(topic.Raw()) {
+		if group := match.Value.group; group != nil {
+			if len(groups[*group]) == 0 {
+				matches = append(matches, match)
+			}
+			groups[*group] = append(groups[*group], match.Value)
+			continue
+		}
+
+		matches = append(matches, match)
+	}
+
+	recipients := make([]localRecipient, 0, len(matches))
+	for _, match := range matches {
+		sub := match.Value
+		if sub.group != nil {
+			members := groups[*sub.group]
+			delivery := b.groupDeliveries[*sub.group].Add(1) - 1
+			sub = members[delivery%uint64(len(members))]
+		}
+
+		// Each subscriber gets its own copy of the headers so handlers can modify them safely.
+		routed := beacon.RoutedMessage{
+			Message: message,
+			Topic:   match.Match,
+		}
+		routed.Headers = maps.Clone(message.Headers)
+
+		recipients = append(recipients, localRecipient{sub: sub, message: routed})
+	}
+
+	return recipients, nil
+}
@@ -0,0 +1,359 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pmoura-dev/beacon"
+)
+
+func Test_LocalBroker(t *testing.T) {
+	broker := NewLocalBroker()
+
+	subTopic, _ := beacon.NewTopic("foo/{foo_id}/topic")
+	if _, err := broker.Subscribe(subTopic); err != ErrLocalBrokerNotConnected {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrLocalBrokerNotConnected, err)
+	}
+
+	if err := broker.Connect(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	messageChan, err := broker.Subscribe(subTopic)
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	pubTopic, _ := beacon.NewTopic("foo/12345/topic")
+	message := beacon.Message{
+		ID:          "1",
+		Timestamp:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		ContentType: "text/plain",
+		Payload:     []byte("hello"),
+	}
+	message.SetHeader("correlation-id", "abc")
+
+	if err := broker.Publish(pubTopic, message); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	select {
+	case got := <-messageChan:
+		if !reflect.DeepEqual(got.Message, message) {
+			t.Fatalf("Test failed! Expected message: %v, got: %v", message, got.Message)
+		}
+		if got.GetTopicParam("foo_id") != "12345" {
+			t.Fatalf("Test failed! Expected param: %s, got: %s", "12345", got.GetTopicParam("foo_id"))
+		}
+		if got.GetHeader("correlation-id") != "abc" {
+			t.Fatalf("Test failed! Expected header: %s, got: %s", "abc", got.GetHeader("correlation-id"))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Message was not delivered.")
+	}
+
+	if err := broker.Disconnect(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if _, ok := <-messageChan; ok {
+		t.Fatal("Test failed! Expected subscription channel to be closed.")
+	}
+}
+
+func Test_LocalBroker_Unsubscribe(t *testing.T) {
+	broker := NewLocalBroker()
+	_ = broker.Connect()
+	defer broker.Disconnect()
+
+	fooTopic, _ := beacon.NewTopic("foo")
+	barTopic, _ := beacon.NewTopic("bar")
+
+	fooChan, _ := broker.Subscribe(fooTopic)
+	barChan, _ := broker.Subscribe(barTopic)
+
+	if err := broker.Unsubscribe(fooTopic); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if _, ok := <-fooChan; ok {
+		t.Fatal("Test failed! Expected subscription channel to be closed.")
+	}
+
+	_ = broker.Publish(fooTopic, beacon.Message{})
+	_ = broker.Publish(barTopic, beacon.Message{Payload: []byte("bar")})
+
+	select {
+	case message := <-barChan:
+		if string(message.Payload) != "bar" {
+			t.Fatalf("Test failed! Expected payload: %s, got: %s", "bar", message.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Message was not delivered.")
+	}
+}
+
+func Test_LocalBroker_UnsubscribeEqualTopic(t *testing.T) {
+	broker := NewLocalBroker()
+	_ = broker.Connect()
+	defer broker.Disconnect()
+
+	// Each subscriber parses the topic on its own, as replicas sharing the broker do.
+	topics := make([]*beacon.Topic, 3)
+	for i := range topics {
+		topics[i], _ = beacon.NewTopic("foo/{foo_id}")
+	}
+
+	firstReplica, _ := broker.Subscribe(topics[0], beacon.WithGroup("workers"))
+	secondReplica, _ := broker.Subscribe(topics[1], beacon.WithGroup("workers"))
+	unshared, _ := broker.Subscribe(topics[2])
+
+	if err := broker.Unsubscribe(topics[0]); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if _, ok := <-firstReplica; ok {
+		t.Fatal("Test failed! Expected subscription channel to be closed.")
+	}
+
+	pubTopic, _ := beacon.NewTopic("foo/12345")
+	_ = broker.Publish(pubTopic, beacon.Message{})
+
+	for name, messageChan := range map[string]<-chan beacon.RoutedMessage{"replica": secondReplica, "unshared": unshared} {
+		select {
+		case _, ok := <-messageChan:
+			if !ok {
+				t.Fatalf("Test failed! Expected %s subscription to be kept.", name)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Test failed! Message was not delivered to %s subscription.", name)
+		}
+	}
+}
+
+func Test_LocalBroker_RequestReply(t *testing.T) {
+	localBroker := NewLocalBroker()
+	r := beacon.NewRouter(beacon.NewBroker(localBroker, localBroker))
+
+	_ = r.AddSubscription("devices/{device_id}/commands", func(publisher beacon.Publisher, message beacon.RoutedMessage) error {
+		return beacon.Reply(publisher, message, beacon.Message{
+			Payload: []byte("ack " + message.GetTopicParam("device_id")),
+		})
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reply, err := r.Request(ctx, "devices/42/commands", beacon.Message{Payload: []byte("reboot")})
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if string(reply.Payload) != "ack 42" {
+		t.Fatalf("Test failed! Expected payload: %s, got: %s", "ack 42", reply.Payload)
+	}
+
+	if reply.GetHeader(beacon.HeaderCorrelationID) == "" {
+		t.Fatal("Test failed! Expected reply to carry the correlation ID.")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.Request(ctx, "devices/42/unknown", beacon.Message{}); !errors.Is(err, beacon.ErrRequestTimeout) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", beacon.ErrRequestTimeout, err)
+	}
+}
+
+func Test_LocalBroker_PublishWildcardTopic(t *testing.T) {
+	broker := NewLocalBroker()
+	_ = broker.Connect()
+	defer broker.Disconnect()
+
+	for _, rawTopic := range []string{"foo/{foo_id}", "foo/+", "foo/#"} {
+		topic, _ := beacon.NewTopic(rawTopic)
+		if err := broker.Publish(topic, beacon.Message{}); err != beacon.ErrWildcardTopic {
+			t.Fatalf("Test failed! Expected error for %s: %v, got: %v", rawTopic, beacon.ErrWildcardTopic, err)
+		}
+	}
+}
+
+func Test_LocalBroker_InvalidBufferSize(t *testing.T) {
+	broker := NewLocalBroker(WithBufferSize(-1))
+
+	if err := broker.Connect(); !errors.Is(err, ErrInvalidLocalBrokerOption) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidLocalBrokerOption, err)
+	}
+
+	topic, _ := beacon.NewTopic("foo")
+	if _, err := broker.Subscribe(topic); err != ErrLocalBrokerNotConnected {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrLocalBrokerNotConnected, err)
+	}
+}
+
+func Test_LocalBroker_ConnectionStateEvents(t *testing.T) {
+	broker := NewLocalBroker()
+
+	var events []beacon.ConnectionEvent
+	broker.OnConnectionStateChange(func(event beacon.ConnectionEvent) {
+		events = append(events, event)
+	})
+
+	_ = broker.Connect()
+	_ = broker.Connect()
+
+	expected := []beacon.ConnectionEvent{{State: beacon.ConnectionConnected}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("Test failed! Expected events: %v, got: %v", expected, events)
+	}
+}
+
+func Test_LocalBroker_Retain(t *testing.T) {
+	broker := NewLocalBroker()
+	_ = broker.Connect()
+	defer broker.Disconnect()
+
+	statusTopic, _ := beacon.NewTopic("services/foo/status")
+	if err := broker.Publish(statusTopic, beacon.Message{Payload: []byte("online")}, beacon.WithRetain()); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	subTopic, _ := beacon.NewTopic("services/{service}/status")
+	messageChan, _ := broker.Subscribe(subTopic)
+
+	select {
+	case received := <-messageChan:
+		if string(received.Payload) != "online" || received.GetTopicParam("service") != "foo" {
+			t.Fatalf("Test failed! Unexpected retained message: %+v", received)
+		}
+	default:
+		t.Fatal("Test failed! Expected the retained message to be delivered on subscribe.")
+	}
+
+	// An empty retained payload clears the retained message.
+	_ = broker.Publish(statusTopic, beacon.Message{}, beacon.WithRetain())
+	<-messageChan
+
+	otherChan, _ := broker.Subscribe(subTopic)
+	select {
+	case received := <-otherChan:
+		t.Fatalf("Test failed! Expected no retained message, got: %+v", received)
+	default:
+	}
+}
+
+func Test_LocalBroker_PublishFromBlockedHandler(t *testing.T) {
+	localBroker := NewLocalBroker(WithBufferSize(1))
+	r := beacon.NewRouter(beacon.NewBroker(localBroker, localBroker))
+
+	// The handler is slower than the publisher, so the subscription fills up while it runs.
+	_ = r.AddSubscription("foo", func(publisher beacon.Publisher, message beacon.RoutedMessage) error {
+		time.Sleep(time.Millisecond)
+		topic, _ := beacon.NewTopic("bar")
+		return publisher.Publish(topic, beacon.Message{})
+	}, beacon.WithDispatchMode(beacon.DispatchOrdered))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 10 {
+			_ = r.Publish("foo", beacon.Message{Payload: []byte("foo")}, beacon.WithRetain())
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Publishing deadlocked.")
+	}
+}
+
+func Test_LocalBroker_UnsupportedPublishOptions(t *testing.T) {
+	broker := NewLocalBroker()
+	_ = broker.Connect()
+	defer broker.Disconnect()
+
+	topic, _ := beacon.NewTopic("foo/bar")
+
+	for _, option := range []beacon.PublishOption{beacon.WithExpiry(time.Minute), beacon.WithPriority(1)} {
+		if err := broker.Publish(topic, beacon.Message{}, option); !errors.Is(err, beacon.ErrUnsupportedPublishOption) {
+			t.Fatalf("Test failed! Expected error: %v, got: %v", beacon.ErrUnsupportedPublishOption, err)
+		}
+	}
+
+	if err := broker.Publish(topic, beacon.Message{}, beacon.WithQoS(2)); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+}
+
+func Test_LocalBroker_ConsumerGroup(t *testing.T) {
+	broker := NewLocalBroker()
+	_ = broker.Connect()
+	defer broker.Disconnect()
+
+	subTopic, _ := beacon.NewTopic("foo/{foo_id}/topic")
+	replicas := make([]<-chan beacon.RoutedMessage, 3)
+	for i := range replicas {
+		replicas[i], _ = broker.Subscribe(subTopic, beacon.WithGroup("workers"))
+	}
+	unshared, _ := broker.Subscribe(subTopic)
+
+	pubTopic, _ := beacon.NewTopic("foo/12345/topic")
+	for range 6 {
+		_ = broker.Publish(pubTopic, beacon.Message{})
+	}
+
+	// Replicas split the messages evenly, while the unshared subscription receives every message.
+	for i, messageChan := range replicas {
+		if len(messageChan) != 2 {
+			t.Fatalf("Test failed! Expected replica %d to receive %d messages, got: %d", i, 2, len(messageChan))
+		}
+
+		if received := <-messageChan; received.GetTopicParam("foo_id") != "12345" {
+			t.Fatalf("Test failed! Expected foo_id: %s, got: %s", "12345", received.GetTopicParam("foo_id"))
+		}
+	}
+
+	if len(unshared) != 6 {
+		t.Fatalf("Test failed! Expected unshared subscription to receive %d messages, got: %d", 6, len(unshared))
+	}
+}
+
+func Test_LocalBroker_DeadLetterLoop(t *testing.T) {
+	broker := NewLocalBroker()
+	r := beacon.NewRouter(beacon.NewBroker(broker, broker), beacon.WithDeadLetter(beacon.DeadLetterPrefix("dlq")))
+
+	// The subscription also matches the dead-letter topic "dlq/foo/1", so it receives the message again.
+	var calls atomic.Int32
+	_ = r.AddSubscription("{device}/*", func(beacon.Publisher, beacon.RoutedMessage) error {
+		calls.Add(1)
+		return errors.New("failure")
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	_ = r.Publish("foo/1", beacon.Message{})
+
+	time.Sleep(50 * time.Millisecond)
+	_ = r.Shutdown(context.Background())
+
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("Test failed! Expected handler calls: %d, got: %d", 2, got)
+	}
+}
@@ -0,0 +1,87 @@
+package brokers
+
+import (
+	"github.com/pmoura-dev/beacon"
+	"github.com/pmoura-dev/beacon/internal/mqttconn"
+	"github.com/pmoura-dev/beacon/mqttclient"
+)
+
+// MQTTBroker is both a beacon.Subscriber and a beacon.Publisher sharing a single MQTT client connection,
+// so it can be passed as both arguments of beacon.NewBroker.
+type MQTTBroker struct {
+	conn   *mqttconn.Conn
+	config mqttconn.Config
+}
+
+type MQTTBrokerOption func(*MQTTBroker)
+
+func NewMQTTBroker(url string, options ...MQTTBrokerOption) *MQTTBroker {
+	broker := &MQTTBroker{
+		config: mqttconn.Config{
+			QOS:                  0,
+			DisconnectionTimeout: 250,
+		},
+	}
+
+	for _, opt := range options {
+		opt(broker)
+	}
+
+	broker.conn = mqttconn.New(url, broker.config)
+
+	return broker
+}
+
+// WithMQTTClientOptions configures the underlying MQTT client, e.g. its TLS configuration or credentials.
+func WithMQTTClientOptions(options ...mqttclient.Option) func(*MQTTBroker) {
+	return func(b *MQTTBroker) {
+		b.config.ClientOptions = append(b.config.ClientOptions, options...)
+	}
+}
+
+func WithMQTTQOS(qos byte) func(*MQTTBroker) {
+	return func(b *MQTTBroker) {
+		b.config.QOS = qos
+	}
+}
+
+func WithMQTTDisconnectionTimeout(timeout uint) func(*MQTTBroker) {
+	return func(b *MQTTBroker) {
+		b.config.DisconnectionTimeout = timeout
+	}
+}
+
+// WithMQTTEnvelope wraps published messages with beacon.MarshalEnvelope and unwraps received ones,
+// so that headers survive MQTT 3.1.1. It is required by request/reply, whose requests fail to publish
+// with beacon.ErrUnsupportedPublishOption otherwise.
+func WithMQTTEnvelope() func(*MQTTBroker) {
+	return func(b *MQTTBroker) {
+		b.config.Envelope = true
+	}
+}
+
+// Connect opens the shared connection. Connecting an already connected broker is a no-op.
+func (b *MQTTBroker) Connect() error {
+	return b.conn.Connect()
+}
+
+// Disconnect closes the shared connection. Disconnecting an already disconnected broker is a no-op.
+func (b *MQTTBroker) Disconnect() error {
+	return b.conn.Disconnect()
+}
+
+func (b *MQTTBroker) OnConnectionStateChange(handler beacon.ConnectionStateHandler) {
+	b.conn.OnConnectionStateChange(handler)
+}
+
+func (b *MQTTBroker) Subscribe(topic *beacon.Topic, options ...beacon.SubscribeOption) (<-chan beacon.RoutedMessage, error) {
+	return b.conn.Subscribe(topic, options...)
+}
+
+func (b *MQTTBroker) Unsubscribe(topic *beacon.Topic) error {
+	return b.conn.Unsubscribe(topic)
+}
+
+func (b *MQTTBroker) Publish(topic *beacon.Topic, message beacon.Message, options ...beacon.PublishOption) error {
+	return b.conn.Publish(topic, message, options...)
+}
@@ -0,0 +1,450 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pmoura-dev/beacon"
+	"github.com/pmoura-dev/beacon/internal/mqttconn"
+	"github.com/pmoura-dev/beacon/internal/mqtttopic"
+	"github.com/pmoura-dev/beacon/mqttclient"
+)
+
+var (
+	ErrMQTT5BrokerNotConnected = errors.New("MQTT v5 broker is not connected")
+	ErrMQTT5ServerDisconnect   = errors.New("MQTT v5 server closed the connection")
+)
+
+// User properties carrying the message fields that have no MQTT v5 property of their own.
+const (
+	mqtt5PropertyMessageID = "beacon-message-id"
+	mqtt5PropertyTimestamp = "beacon-timestamp"
+)
+
+// MQTT5Broker is a beacon.Subscriber and beacon.Publisher speaking MQTT v5 over a single connection.
+// Message headers travel as user properties, while the reply-to and correlation-id headers used by
+// request/reply are mapped to the response topic and correlation data properties. The connection is
+// re-established when it drops and every active subscription is restored.
+type MQTT5Broker struct {
+	url       *url.URL
+	clientErr error // error parsing the URL or building the client options, returned by Connect
+
+	config               autopaho.ClientConfig
+	clientOptions        []mqttclient.Option
+	qos                  byte
+	disconnectionTimeout uint // milliseconds
+
+	manager   *autopaho.ConnectionManager
+	cancel    context.CancelFunc
+	managerMu sync.Mutex
+
+	// Whether the first connection was established, after which connection errors mean reconnecting.
+	established atomic.Bool
+	connectErr  atomic.Pointer[error]
+
+	subscriptions *mqttconn.Subscriptions
+
+	// Whether the server supports subscription identifiers, used to route messages to the subscriptions
+	// of the filter they were delivered through.
+	subscriptionIDs atomic.Bool
+
+	stateHandlers   []beacon.ConnectionStateHandler
+	stateHandlersMu sync.Mutex
+}
+
+type MQTT5BrokerOption func(*MQTT5Broker)
+
+func NewMQTT5Broker(rawURL string, options ...MQTT5BrokerOption) *MQTT5Broker {
+	broker := &MQTT5Broker{
+		qos:                  0,
+		disconnectionTimeout: 250,
+		subscriptions:        mqttconn.NewSubscriptions(),
+	}
+
+	for _, opt := range options {
+		opt(broker)
+	}
+
+	broker.url, broker.clientErr = url.Parse(rawURL)
+
+	clientOptions, err := mqttclient.NewClientOptions(rawURL, broker.clientOptions...)
+	if err != nil {
+		broker.clientErr = err
+		clientOptions, _ = mqttclient.NewClientOptions(rawURL)
+	}
+
+	broker.config = newMQTT5ClientConfig(clientOptions)
+
+	return broker
+}
+
+// WithMQTT5ClientOptions configures the client with the options shared with the MQTT 3.1.1 transports,
+// e.g. its TLS configuration, credentials or last will.
+func WithMQTT5ClientOptions(options ...mqttclient.Option) func(*MQTT5Broker) {
+	return func(b *MQTT5Broker) {
+		b.clientOptions = append(b.clientOptions, options...)
+	}
+}
+
+func WithMQTT5QOS(qos byte) func(*MQTT5Broker) {
+	return func(b *MQTT5Broker) {
+		b.qos = qos
+	}
+}
+
+func WithMQTT5DisconnectionTimeout(timeout uint) func(*MQTT5Broker) {
+	return func(b *MQTT5Broker) {
+		b.disconnectionTimeout = timeout
+	}
+}
+
+// newMQTT5ClientConfig translates the MQTT client options into the configuration of the v5 client. The
+// client ID defaults to empty, in which case the server assigns one.
+func newMQTT5ClientConfig(opts *mqtt.ClientOptions) autopaho.ClientConfig {
+	config := autopaho.ClientConfig{
+		TlsCfg:                        opts.TLSConfig,
+		KeepAlive:                     uint16(opts.KeepAlive),
+		ConnectTimeout:                opts.ConnectTimeout,
+		CleanStartOnInitialConnection: opts.CleanSession,
+		ConnectUsername:               opts.Username,
+		ConnectPassword:               []byte(opts.Password),
+	}
+	config.ClientID = opts.ClientID
+
+	// In MQTT v5 a session ends with the connection unless it has an expiry interval, so a session that is
+	// not clean never expires, as in MQTT 3.1.1.
+	if !opts.CleanSession {
+		config.SessionExpiryInterval = math.MaxUint32
+	}
+
+	if opts.CredentialsProvider != nil {
+		provider := opts.CredentialsProvider
+		config.ConnectPacketBuilder = func(connect *paho.Connect, _ *url.URL) (*paho.Connect, error) {
+			username, password := provider()
+			connect.Username, connect.UsernameFlag = username, username != ""
+			connect.Password, connect.PasswordFlag = []byte(password), password != ""
+			return connect, nil
+		}
+	}
+
+	if opts.WillEnabled {
+		config.WillMessage = &paho.WillMessage{
+			Topic:   opts.WillTopic,
+			Payload: opts.WillPayload,
+			QoS:     opts.WillQos,
+			Retain:  opts.WillRetained,
+		}
+	}
+
+	return config
+}
+
+// Connect establishes the connection, waiting for the first attempt to succeed. Connecting an already
+// connected broker is a no-op.
+func (b *MQTT5Broker) Connect() error {
+	if b.clientErr != nil {
+		return b.clientErr
+	}
+
+	b.managerMu.Lock()
+	defer b.managerMu.Unlock()
+
+	if b.manager != nil {
+		return nil
+	}
+
+	config := b.config
+	config.ServerUrls = []*url.URL{b.url}
+	config.OnConnectionUp = b.onConnectionUp
+	config.OnConnectError = b.onConnectError
+	config.OnClientError = func(err error) {
+		b.notify(beacon.ConnectionEvent{State: beacon.ConnectionLost, Err: err})
+	}
+	config.OnServerDisconnect = func(d *paho.Disconnect) {
+		err := fmt.Errorf("%w: reason code %d", ErrMQTT5ServerDisconnect, d.ReasonCode)
+		b.notify(beacon.ConnectionEvent{State: beacon.ConnectionLost, Err: err})
+	}
+	config.OnPublishReceived = []func(paho.PublishReceived) (bool, error){b.route}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	manager, err := autopaho.NewConnection(ctx, config)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	timeout := config.ConnectTimeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	awaitCtx, cancelAwait := context.WithTimeout(ctx, timeout)
+	defer cancelAwait()
+
+	if err := manager.AwaitConnection(awaitCtx); err != nil {
+		cancel()
+		if connectErr := b.connectErr.Load(); connectErr != nil {
+			return *connectErr
+		}
+		return err
+	}
+
+	b.manager = manager
+	b.cancel = cancel
+	return nil
+}
+
+// Disconnect closes the connection. Disconnecting an already disconnected broker is a no-op.
+func (b *MQTT5Broker) Disconnect() error {
+	b.managerMu.Lock()
+	defer b.managerMu.Unlock()
+
+	if b.manager == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(b.disconnectionTimeout)*time.Millisecond)
+	defer cancel()
+
+	err := b.manager.Disconnect(ctx)
+	b.cancel()
+
+	b.manager = nil
+	b.established.Store(false)
+
+	return err
+}
+
+func (b *MQTT5Broker) OnConnectionStateChange(handler beacon.ConnectionStateHandler) {
+	b.stateHandlersMu.Lock()
+	defer b.stateHandlersMu.Unlock()
+
+	b.stateHandlers = append(b.stateHandlers, handler)
+}
+
+func (b *MQTT5Broker) Subscribe(topic *beacon.Topic, options ...beacon.SubscribeOption) (<-chan beacon.RoutedMessage, error) {
+	opts, err := beacon.NewSubscribeOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := b.connection()
+	if manager == nil {
+		return nil, ErrMQTT5BrokerNotConnected
+	}
+
+	sub, err := b.subscriptions.Add(topic, mqtttopic.SharedFilter(topic, opts.Group), func(filter string) error {
+		return b.subscribe(manager, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sub.Messages(), nil
+}
+
+func (b *MQTT5Broker) Unsubscribe(topic *beacon.Topic) error {
+	manager := b.connection()
+	if manager == nil {
+		return ErrMQTT5BrokerNotConnected
+	}
+
+	return b.subscriptions.Remove(topic, func(filter string) error {
+		_, err := manager.Unsubscribe(context.Background(), &paho.Unsubscribe{Topics: []string{filter}})
+		return err
+	})
+}
+
+func (b *MQTT5Broker) Publish(topic *beacon.Topic, message beacon.Message, options ...beacon.PublishOption) error {
+	if topic.HasWildcards() {
+		return beacon.ErrWildcardTopic
+	}
+
+	opts, err := beacon.NewPublishOptions(options...)
+	if err != nil {
+		return err
+	}
+
+	if opts.Priority != nil {
+		return fmt.Errorf("%w: MQTT does not support message priority", beacon.ErrUnsupportedPublishOption)
+	}
+
+	manager := b.connection()
+	if manager == nil {
+		return ErrMQTT5BrokerNotConnected
+	}
+
+	publish := &paho.Publish{
+		QoS:        b.qos,
+		Retain:     opts.Retain,
+		Topic:      topic.Raw(),
+		Properties: toMQTT5Properties(message),
+		Payload:    message.Payload,
+	}
+
+	if opts.QoS != nil {
+		publish.QoS = *opts.QoS
+	}
+
+	// Message expiry has a resolution of seconds, so it is rounded up to not expire messages early.
+	if opts.Expiry > 0 {
+		seconds := uint32((opts.Expiry + time.Second - 1) / time.Second)
+		publish.Properties.MessageExpiry = &seconds
+	}
+
+	_, err = manager.Publish(context.Background(), publish)
+	return err
+}
+
+func (b *MQTT5Broker) connection() *autopaho.ConnectionManager {
+	b.managerMu.Lock()
+	defer b.managerMu.Unlock()
+
+	return b.manager
+}
+
+func (b *MQTT5Broker) subscribe(manager *autopaho.ConnectionManager, filter string) error {
+	subscribe := &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: filter, QoS: b.qos}},
+	}
+
+	if b.subscriptionIDs.Load() {
+		identifier := b.subscriptions.Identifier(filter)
+		subscribe.Properties = &paho.SubscribeProperties{SubscriptionIdentifier: &identifier}
+	}
+
+	_, err := manager.Subscribe(context.Background(), subscribe)
+	return err
+}
+
+// onConnectionUp restores the subscriptions, unless the server kept them in the session, and reports the
+// connection as established.
+func (b *MQTT5Broker) onConnectionUp(manager *autopaho.ConnectionManager, connack *paho.Connack) {
+	b.established.Store(true)
+	b.connectErr.Store(nil)
+	b.subscriptionIDs.Store(connack.Properties == nil || connack.Properties.SubIDAvailable)
+
+	var err error
+	if !connack.SessionPresent {
+		err = b.resubscribe(manager)
+	}
+
+	b.notify(beacon.ConnectionEvent{State: beacon.ConnectionConnected, Err: err})
+}
+
+func (b *MQTT5Broker) onConnectError(err error) {
+	b.connectErr.Store(&err)
+
+	// Errors before the first connection are returned by Connect instead.
+	if b.established.Load() {
+		b.notify(beacon.ConnectionEvent{State: beacon.ConnectionReconnecting})
+	}
+}
+
+func (b *MQTT5Broker) resubscribe(manager *autopaho.ConnectionManager) error {
+	var errs []error
+	for _, filter := range b.subscriptions.Filters() {
+		if err := b.subscribe(manager, filter); err != nil {
+			errs = append(errs, fmt.Errorf("%w %q: %w", mqttconn.ErrResubscribe, filter, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b *MQTT5Broker) notify(event beacon.ConnectionEvent) {
+	b.stateHandlersMu.Lock()
+	handlers := slices.Clone(b.stateHandlers)
+	b.stateHandlersMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// route delivers a received message to the subscriptions of the filter it was delivered through, so that
+// overlapping subscriptions, e.g. a shared and a non-shared one, only get the messages meant for them.
+// Messages without a subscription identifier go to every subscription whose topic matches them. The
+// client keeps a single identifier per message, so servers must send a copy for each matching filter,
+// as most do.
+func (b *MQTT5Broker) route(received paho.PublishReceived) (bool, error) {
+	publish := received.Packet
+	message := fromMQTT5Publish(publish)
+
+	if publish.Properties != nil && publish.Properties.SubscriptionIdentifier != nil {
+		b.subscriptions.DispatchIdentifier(*publish.Properties.SubscriptionIdentifier, publish.Topic, message)
+		return true, nil
+	}
+
+	b.subscriptions.Route(publish.Topic, message)
+	return true, nil
+}
+
+func toMQTT5Properties(message beacon.Message) *paho.PublishProperties {
+	properties := &paho.PublishProperties{
+		ContentType: message.ContentType,
+	}
+
+	for key, value := range message.Headers {
+		switch key {
+		case beacon.HeaderReplyTo:
+			properties.ResponseTopic = value
+		case beacon.HeaderCorrelationID:
+			properties.CorrelationData = []byte(value)
+		default:
+			properties.User.Add(key, value)
+		}
+	}
+
+	if message.ID != "" {
+		properties.User.Add(mqtt5PropertyMessageID, message.ID)
+	}
+
+	if !message.Timestamp.IsZero() {
+		properties.User.Add(mqtt5PropertyTimestamp, message.Timestamp.Format(time.RFC3339Nano))
+	}
+
+	return properties
+}
+
+func fromMQTT5Publish(publish *paho.Publish) beacon.Message {
+	message := beacon.Message{Payload: publish.Payload}
+
+	properties := publish.Properties
+	if properties == nil {
+		return message
+	}
+
+	message.ContentType = properties.ContentType
+
+	for _, property := range properties.User {
+		switch property.Key {
+		case mqtt5PropertyMessageID:
+			message.ID = property.Value
+		case mqtt5PropertyTimestamp:
+			message.Timestamp, _ = time.Parse(time.RFC3339Nano, property.Value)
+		default:
+			message.SetHeader(property.Key, property.Value)
+		}
+	}
+
+	if properties.ResponseTopic != "" {
+		message.SetHeader(beacon.HeaderReplyTo, properties.ResponseTopic)
+	}
+
+	if properties.CorrelationData != nil {
+		message.SetHeader(beacon.HeaderCorrelationID, string(properties.CorrelationData))
+	}
+
+	return message
+}
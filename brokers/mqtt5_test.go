@@ -0,0 +1,501 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/pmoura-dev/beacon"
+	"github.com/pmoura-dev/beacon/internal/mqtttopic"
+	"github.com/pmoura-dev/beacon/mqttclient"
+)
+
+// mqtt5TestServer is a minimal in-memory MQTT v5 server, enough to exercise MQTT5Broker: it acknowledges
+// connections and subscriptions and forwards QoS 0 and 1 publishes, with their properties, to matching
+// subscriptions. Like most servers, it sends a copy for each matching subscription of a connection, with
+// the subscription identifier of that subscription, if any.
+type mqtt5TestServer struct {
+	mu            sync.Mutex
+	conns         []net.Conn
+	subscriptions map[net.Conn][]string
+	identifiers   map[string]*int
+	connects      int
+}
+
+func newMQTT5TestServer() *mqtt5TestServer {
+	return &mqtt5TestServer{
+		subscriptions: make(map[net.Conn][]string),
+		identifiers:   make(map[string]*int),
+	}
+}
+
+func (s *mqtt5TestServer) dial(_ context.Context, _ autopaho.ClientConfig, _ *url.URL) (net.Conn, error) {
+	// Packets are written with several writes, which net.Pipe may interleave between goroutines, e.g. the
+	// first PINGREQ with a SUBSCRIBE.
+	client, server := net.Pipe()
+	client, server = packets.NewThreadSafeConn(client), packets.NewThreadSafeConn(server)
+
+	s.mu.Lock()
+	s.conns = append(s.conns, server)
+	s.mu.Unlock()
+
+	go s.serve(server)
+	return client, nil
+}
+
+// drop closes every connection without a DISCONNECT, as a network failure would.
+func (s *mqtt5TestServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+	s.subscriptions = make(map[net.Conn][]string)
+}
+
+func (s *mqtt5TestServer) filters() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var filters []string
+	for _, connFilters := range s.subscriptions {
+		filters = append(filters, connFilters...)
+	}
+	return filters
+}
+
+func (s *mqtt5TestServer) connectCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connects
+}
+
+func (s *mqtt5TestServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch p := packet.Content.(type) {
+		case *packets.Connect:
+			s.mu.Lock()
+			s.connects++
+			s.mu.Unlock()
+			_, _ = (&packets.Connack{Properties: &packets.Properties{}}).WriteTo(conn)
+		case *packets.Subscribe:
+			reasons := make([]byte, len(p.Subscriptions))
+			s.mu.Lock()
+			for i, sub := range p.Subscriptions {
+				s.subscriptions[conn] = append(s.subscriptions[conn], sub.Topic)
+				if p.Properties != nil {
+					s.identifiers[sub.Topic] = p.Properties.SubscriptionIdentifier
+				}
+				reasons[i] = sub.QoS
+			}
+			s.mu.Unlock()
+			_, _ = (&packets.Suback{PacketID: p.PacketID, Reasons: reasons, Properties: &packets.Properties{}}).WriteTo(conn)
+		case *packets.Unsubscribe:
+			s.mu.Lock()
+			for _, topic := range p.Topics {
+				filters := s.subscriptions[conn]
+				for i, filter := range filters {
+					if filter == topic {
+						s.subscriptions[conn] = append(filters[:i], filters[i+1:]...)
+						break
+					}
+				}
+			}
+			s.mu.Unlock()
+			reasons := make([]byte, len(p.Topics))
+			_, _ = (&packets.Unsuback{PacketID: p.PacketID, Reasons: reasons, Properties: &packets.Properties{}}).WriteTo(conn)
+		case *packets.Publish:
+			if p.QoS == 1 {
+				_, _ = (&packets.Puback{PacketID: p.PacketID, Properties: &packets.Properties{}}).WriteTo(conn)
+			}
+			s.forward(p)
+		case *packets.Pingreq:
+			_, _ = (&packets.Pingresp{}).WriteTo(conn)
+		case *packets.Disconnect:
+			return
+		}
+	}
+}
+
+func (s *mqtt5TestServer) forward(publish *packets.Publish) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn, filters := range s.subscriptions {
+		for _, filter := range filters {
+			if mqttFilterMatches(mqtttopic.Unshare(filter), publish.Topic) {
+				properties := *publish.Properties
+				properties.SubscriptionIdentifier = s.identifiers[filter]
+
+				_, _ = (&packets.Publish{
+					Topic:      publish.Topic,
+					Payload:    publish.Payload,
+					Properties: &properties,
+				}).WriteTo(conn)
+			}
+		}
+	}
+}
+
+func mqttFilterMatches(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
+
+func newTestMQTT5Broker(server *mqtt5TestServer) *MQTT5Broker {
+	broker := NewMQTT5Broker("mqtt://stand-in:1883", WithMQTT5DisconnectionTimeout(50))
+	broker.config.AttemptConnection = server.dial
+	broker.config.ReconnectBackoff = autopaho.NewConstantBackoff(10 * time.Millisecond)
+	return broker
+}
+
+func receive(t *testing.T, messageChan <-chan beacon.RoutedMessage) beacon.RoutedMessage {
+	t.Helper()
+
+	select {
+	case message := <-messageChan:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test failed! Timed out waiting for a message.")
+		return beacon.RoutedMessage{}
+	}
+}
+
+func Test_MQTT5Broker(t *testing.T) {
+	server := newMQTT5TestServer()
+	broker := newTestMQTT5Broker(server)
+
+	if err := broker.Connect(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer broker.Disconnect()
+
+	subTopic, _ := beacon.NewTopic("devices/{device_id}/state")
+	messageChan, err := broker.Subscribe(subTopic)
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	message := beacon.Message{
+		ID:          "1",
+		Timestamp:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		ContentType: "application/json",
+		Payload:     []byte(`{"on":true}`),
+	}
+	message.SetHeader("trace-id", "abc")
+	message.SetHeader(beacon.HeaderReplyTo, "replies/1")
+	message.SetHeader(beacon.HeaderCorrelationID, "42")
+
+	pubTopic, _ := beacon.NewTopic("devices/lamp/state")
+	if err := broker.Publish(pubTopic, message, beacon.WithQoS(1), beacon.WithExpiry(time.Minute)); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	received := receive(t, messageChan)
+
+	if received.GetTopicParam("device_id") != "lamp" {
+		t.Fatalf("Test failed! Expected device_id: %s, got: %s", "lamp", received.GetTopicParam("device_id"))
+	}
+	if received.ID != message.ID || !received.Timestamp.Equal(message.Timestamp) || received.ContentType != message.ContentType {
+		t.Fatalf("Test failed! Expected message fields: %+v, got: %+v", message, received.Message)
+	}
+	if string(received.Payload) != string(message.Payload) {
+		t.Fatalf("Test failed! Expected payload: %s, got: %s", message.Payload, received.Payload)
+	}
+	for _, header := range []string{"trace-id", beacon.HeaderReplyTo, beacon.HeaderCorrelationID} {
+		if received.GetHeader(header) != message.GetHeader(header) {
+			t.Fatalf("Test failed! Expected header %s: %s, got: %s", header, message.GetHeader(header), received.GetHeader(header))
+		}
+	}
+
+	if err := broker.Publish(pubTopic, beacon.Message{}, beacon.WithPriority(1)); !errors.Is(err, beacon.ErrUnsupportedPublishOption) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", beacon.ErrUnsupportedPublishOption, err)
+	}
+
+	wildcardTopic, _ := beacon.NewTopic("devices/{device_id}/state")
+	if err := broker.Publish(wildcardTopic, beacon.Message{}); err != beacon.ErrWildcardTopic {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", beacon.ErrWildcardTopic, err)
+	}
+}
+
+func Test_MQTT5Broker_Reconnect(t *testing.T) {
+	server := newMQTT5TestServer()
+	broker := newTestMQTT5Broker(server)
+
+	events := make(chan beacon.ConnectionEvent, 16)
+	broker.OnConnectionStateChange(func(event beacon.ConnectionEvent) {
+		events <- event
+	})
+
+	if err := broker.Connect(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer broker.Disconnect()
+
+	subTopic, _ := beacon.NewTopic("foo/{foo_id}")
+	messageChan, _ := broker.Subscribe(subTopic)
+
+	waitForState := func(state beacon.ConnectionState) beacon.ConnectionEvent {
+		t.Helper()
+
+		for {
+			select {
+			case event := <-events:
+				if event.State == state {
+					return event
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("Test failed! Timed out waiting for state: %s", state)
+			}
+		}
+	}
+
+	waitForState(beacon.ConnectionConnected)
+
+	server.drop()
+
+	waitForState(beacon.ConnectionLost)
+	if event := waitForState(beacon.ConnectionConnected); event.Err != nil {
+		t.Fatalf("Test failed! Unexpected error restoring subscriptions: %v", event.Err)
+	}
+
+	if server.connectCount() != 2 {
+		t.Fatalf("Test failed! Expected %d connections, got: %d", 2, server.connectCount())
+	}
+
+	pubTopic, _ := beacon.NewTopic("foo/1")
+	if err := broker.Publish(pubTopic, beacon.Message{Payload: []byte("after reconnect")}); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if received := receive(t, messageChan); string(received.Payload) != "after reconnect" {
+		t.Fatalf("Test failed! Expected payload: %s, got: %s", "after reconnect", received.Payload)
+	}
+}
+
+func Test_MQTT5Broker_RequestReply(t *testing.T) {
+	mqtt5Broker := newTestMQTT5Broker(newMQTT5TestServer())
+	r := beacon.NewRouter(beacon.NewBroker(mqtt5Broker, mqtt5Broker))
+
+	_ = r.AddSubscription("devices/{device_id}/commands", func(publisher beacon.Publisher, message beacon.RoutedMessage) error {
+		return beacon.Reply(publisher, message, beacon.Message{
+			Payload: []byte("ack " + message.GetTopicParam("device_id")),
+		})
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	reply, err := r.Request(ctx, "devices/42/commands", beacon.Message{Payload: []byte("reboot")})
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if string(reply.Payload) != "ack 42" {
+		t.Fatalf("Test failed! Expected payload: %s, got: %s", "ack 42", reply.Payload)
+	}
+}
+
+func Test_MQTT5Broker_SharedSubscription(t *testing.T) {
+	server := newMQTT5TestServer()
+	broker := newTestMQTT5Broker(server)
+
+	if err := broker.Connect(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer broker.Disconnect()
+
+	subTopic, _ := beacon.NewTopic("foo/{foo_id}/topic")
+	messageChan, err := broker.Subscribe(subTopic, beacon.WithGroup("workers"))
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if filters := server.filters(); len(filters) != 1 || filters[0] != "$share/workers/foo/+/topic" {
+		t.Fatalf("Test failed! Expected filters: %v, got: %v", []string{"$share/workers/foo/+/topic"}, filters)
+	}
+
+	pubTopic, _ := beacon.NewTopic("foo/12345/topic")
+	_ = broker.Publish(pubTopic, beacon.Message{})
+
+	if received := receive(t, messageChan); received.GetTopicParam("foo_id") != "12345" {
+		t.Fatalf("Test failed! Expected foo_id: %s, got: %s", "12345", received.GetTopicParam("foo_id"))
+	}
+
+	if err := broker.Unsubscribe(subTopic); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if filters := server.filters(); len(filters) != 0 {
+		t.Fatalf("Test failed! Expected no filters, got: %v", filters)
+	}
+}
+
+func Test_newMQTT5ClientConfig(t *testing.T) {
+	rotation := 0
+	broker := NewMQTT5Broker("mqtt://stand-in:1883", WithMQTT5ClientOptions(
+		mqttclient.WithClientID("beacon"),
+		mqttclient.WithCredentialsProvider(func() (string, string) {
+			rotation++
+			return "user", fmt.Sprintf("secret-%d", rotation)
+		}),
+		mqttclient.WithKeepAlive(time.Minute),
+		mqttclient.WithCleanSession(false),
+		mqttclient.WithWill("services/foo/status", []byte(beacon.PresenceOffline), 1, true),
+	))
+
+	config := broker.config
+	if config.ClientID != "beacon" || config.KeepAlive != 60 {
+		t.Fatalf("Test failed! Expected client ID %s and keep alive %d, got: %s and %d", "beacon", 60, config.ClientID, config.KeepAlive)
+	}
+
+	if config.CleanStartOnInitialConnection || config.SessionExpiryInterval == 0 {
+		t.Fatal("Test failed! Expected the session to be kept.")
+	}
+
+	will := config.WillMessage
+	if will == nil || will.Topic != "services/foo/status" || string(will.Payload) != beacon.PresenceOffline || will.QoS != 1 || !will.Retain {
+		t.Fatalf("Test failed! Unexpected will: %+v", will)
+	}
+
+	// Credentials are obtained on every connection attempt.
+	for _, expected := range []string{"secret-1", "secret-2"} {
+		connect, err := config.ConnectPacketBuilder(&paho.Connect{}, nil)
+		if err != nil {
+			t.Fatalf("Test failed! Unexpected error: %v", err)
+		}
+
+		if connect.Username != "user" || string(connect.Password) != expected {
+			t.Fatalf("Test failed! Expected credentials: %s/%s, got: %s/%s", "user", expected, connect.Username, connect.Password)
+		}
+	}
+}
+
+func Test_NewMQTT5Broker_InvalidClientOptions(t *testing.T) {
+	broker := NewMQTT5Broker("mqtt://stand-in:1883", WithMQTT5ClientOptions(mqttclient.WithCACertFile("missing.pem")))
+
+	if err := broker.Connect(); err == nil {
+		t.Fatal("Test failed! Expected an error connecting with invalid client options.")
+	}
+}
+
+func Test_MQTT5Broker_SameFilter(t *testing.T) {
+	mqtt5Broker := newTestMQTT5Broker(newMQTT5TestServer())
+	r := beacon.NewRouter(beacon.NewBroker(mqtt5Broker, mqtt5Broker), beacon.WithOverlapPolicy(beacon.OverlapMostSpecific))
+
+	// Both topics map to the filter "foo/+", but only one of them handles each message.
+	received := make(chan string, 2)
+	_ = r.AddSubscription("foo/{foo_id}", func(_ beacon.Publisher, message beacon.RoutedMessage) error {
+		received <- "any " + message.GetTopicParam("foo_id")
+		return nil
+	})
+	_ = r.AddSubscription("foo/{foo_id:int}", func(_ beacon.Publisher, message beacon.RoutedMessage) error {
+		received <- "int " + message.GetTopicParam("foo_id")
+		return nil
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	expect := func(expected string) {
+		t.Helper()
+
+		select {
+		case got := <-received:
+			if got != expected {
+				t.Fatalf("Test failed! Expected: %s, got: %s", expected, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Test failed! Timed out waiting for: %s", expected)
+		}
+	}
+
+	_ = r.Publish("foo/1", beacon.Message{})
+	expect("int 1")
+
+	_ = r.Publish("foo/abc", beacon.Message{})
+	expect("any abc")
+
+	// Removing one of the subscriptions keeps the filter for the other one.
+	if err := r.RemoveSubscription("foo/{foo_id:int}"); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	_ = r.Publish("foo/2", beacon.Message{})
+	expect("any 2")
+}
+
+func Test_MQTT5Broker_OverlappingSharedSubscription(t *testing.T) {
+	server := newMQTT5TestServer()
+	broker := newTestMQTT5Broker(server)
+
+	if err := broker.Connect(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer broker.Disconnect()
+
+	// The server sends a copy of the message for each filter, and each copy is only meant for the
+	// subscriptions of its filter.
+	sharedTopic, _ := beacon.NewTopic("foo/{foo_id}")
+	sharedChan, _ := broker.Subscribe(sharedTopic, beacon.WithGroup("workers"))
+
+	allTopic, _ := beacon.NewTopic("foo/*")
+	allChan, _ := broker.Subscribe(allTopic)
+
+	pubTopic, _ := beacon.NewTopic("foo/1")
+	_ = broker.Publish(pubTopic, beacon.Message{})
+
+	var shared, all int
+	timeout := time.After(100 * time.Millisecond)
+	for done := false; !done; {
+		select {
+		case <-sharedChan:
+			shared++
+		case <-allChan:
+			all++
+		case <-timeout:
+			done = true
+		}
+	}
+
+	if shared != 1 || all != 1 {
+		t.Fatalf("Test failed! Expected one message for each subscription, got: %d shared and %d non-shared", shared, all)
+	}
+}
@@ -0,0 +1,332 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/pmoura-dev/beacon"
+	"github.com/pmoura-dev/beacon/internal/mqtttopic"
+)
+
+var (
+	_ beacon.Subscriber = (*MQTTBroker)(nil)
+	_ beacon.Publisher  = (*MQTTBroker)(nil)
+)
+
+// mqttTestServer is a minimal in-memory MQTT 3.1.1 server, enough to exercise MQTTBroker: it acknowledges
+// connections and subscriptions and forwards QoS 0 publishes to matching subscriptions.
+type mqttTestServer struct {
+	mu            sync.Mutex
+	conns         []net.Conn
+	connects      int
+	subscriptions map[net.Conn][]string
+	published     []*packets.PublishPacket
+}
+
+func newMQTTTestServer() *mqttTestServer {
+	return &mqttTestServer{
+		subscriptions: make(map[net.Conn][]string),
+	}
+}
+
+func (s *mqttTestServer) open(_ *url.URL, _ mqtt.ClientOptions) (net.Conn, error) {
+	client, server := net.Pipe()
+
+	s.mu.Lock()
+	s.conns = append(s.conns, server)
+	s.mu.Unlock()
+
+	go s.serve(server)
+	return client, nil
+}
+
+// drop closes every connection without a DISCONNECT, as a network failure would.
+func (s *mqttTestServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+	s.subscriptions = make(map[net.Conn][]string)
+}
+
+func (s *mqttTestServer) connectCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connects
+}
+
+func (s *mqttTestServer) filters() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var filters []string
+	for _, connFilters := range s.subscriptions {
+		filters = append(filters, connFilters...)
+	}
+
+	return filters
+}
+
+func (s *mqttTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			s.mu.Lock()
+			s.connects++
+			s.mu.Unlock()
+
+			_ = packets.NewControlPacket(packets.Connack).Write(conn)
+		case *packets.SubscribePacket:
+			s.mu.Lock()
+			s.subscriptions[conn] = append(s.subscriptions[conn], p.Topics...)
+			s.mu.Unlock()
+
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			suback.ReturnCodes = p.Qoss
+			_ = suback.Write(conn)
+		case *packets.UnsubscribePacket:
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = p.MessageID
+			_ = unsuback.Write(conn)
+		case *packets.PublishPacket:
+			s.forward(p)
+		case *packets.PingreqPacket:
+			_ = packets.NewControlPacket(packets.Pingresp).Write(conn)
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+func (s *mqttTestServer) forward(publish *packets.PublishPacket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.published = append(s.published, publish)
+
+	for conn, filters := range s.subscriptions {
+		for _, filter := range filters {
+			if mqttFilterMatches(mqtttopic.Unshare(filter), publish.TopicName) {
+				forwarded := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+				forwarded.TopicName = publish.TopicName
+				forwarded.Payload = publish.Payload
+				_ = forwarded.Write(conn)
+				break
+			}
+		}
+	}
+}
+
+func newTestMQTTBroker(server *mqttTestServer, options ...MQTTBrokerOption) *MQTTBroker {
+	connectToServer := func(o *mqtt.ClientOptions) error {
+		o.SetCustomOpenConnectionFn(server.open)
+		return nil
+	}
+
+	options = append(options, WithMQTTClientOptions(connectToServer), WithMQTTDisconnectionTimeout(50))
+	return NewMQTTBroker("mqtt://stand-in:1883", options...)
+}
+
+func Test_MQTTBroker_DisconnectWithoutConnect(t *testing.T) {
+	broker := NewMQTTBroker("mqtt://localhost:1883")
+
+	if err := broker.Disconnect(); err != nil {
+		t.Fatalf("Test failed! Expected no error, got: %v", err)
+	}
+
+	topic, _ := beacon.NewTopic("foo/{foo_id}")
+	if err := broker.Publish(topic, beacon.Message{}); err != beacon.ErrWildcardTopic {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", beacon.ErrWildcardTopic, err)
+	}
+}
+
+func Test_MQTTBroker_RequestReply(t *testing.T) {
+	mqttBroker := newTestMQTTBroker(newMQTTTestServer(), WithMQTTEnvelope())
+	r := beacon.NewRouter(beacon.NewBroker(mqttBroker, mqttBroker))
+
+	_ = r.AddSubscription("devices/{device_id}/commands", func(publisher beacon.Publisher, message beacon.RoutedMessage) error {
+		return beacon.Reply(publisher, message, beacon.Message{
+			Payload: []byte("ack " + message.GetTopicParam("device_id")),
+		})
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	reply, err := r.Request(ctx, "devices/42/commands", beacon.Message{Payload: []byte("reboot")})
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if string(reply.Payload) != "ack 42" {
+		t.Fatalf("Test failed! Expected payload: %s, got: %s", "ack 42", reply.Payload)
+	}
+}
+
+func Test_MQTTBroker_RequestWithoutEnvelope(t *testing.T) {
+	mqttBroker := newTestMQTTBroker(newMQTTTestServer())
+	r := beacon.NewRouter(beacon.NewBroker(mqttBroker, mqttBroker))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	// The request fails right away instead of waiting for a reply that can not arrive.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := r.Request(ctx, "devices/42/commands", beacon.Message{}); !errors.Is(err, beacon.ErrUnsupportedPublishOption) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", beacon.ErrUnsupportedPublishOption, err)
+	}
+}
+
+func Test_MQTTBroker_AddSubscriptionWhileHandling(t *testing.T) {
+	mqttBroker := newTestMQTTBroker(newMQTTTestServer())
+	r := beacon.NewRouter(beacon.NewBroker(mqttBroker, mqttBroker), beacon.WithOverlapPolicy(beacon.OverlapMostSpecific))
+
+	handled := make(chan struct{}, 20)
+	_ = r.AddSubscription("foo/{foo_id}", func(beacon.Publisher, beacon.RoutedMessage) error {
+		time.Sleep(time.Millisecond)
+		handled <- struct{}{}
+		return nil
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	for range 20 {
+		_ = r.Publish("foo/1", beacon.Message{})
+	}
+	<-handled
+
+	// Subscribing waits for the SUBACK, which is read by the client only once the messages received
+	// before it are handed to the subscription.
+	added := make(chan error, 1)
+	go func() {
+		added <- r.AddSubscription("bar/{bar_id}", func(beacon.Publisher, beacon.RoutedMessage) error { return nil })
+	}()
+
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Fatalf("Test failed! Unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test failed! Adding the subscription did not complete.")
+	}
+}
+
+func Test_MQTTBroker_PresenceWithEnvelope(t *testing.T) {
+	server := newMQTTTestServer()
+	mqttBroker := newTestMQTTBroker(server, WithMQTTEnvelope())
+	r := beacon.NewRouter(beacon.NewBroker(mqttBroker, mqttBroker), beacon.WithPresence("services/foo/status"))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	_ = r.Shutdown(context.Background())
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	// Presence is published as is, like the last will, instead of wrapped in the envelope.
+	var payloads []string
+	for _, publish := range server.published {
+		if publish.TopicName != "services/foo/status" || !publish.Retain {
+			t.Fatalf("Test failed! Expected retained publish to %s, got: %s (retain: %v)", "services/foo/status", publish.TopicName, publish.Retain)
+		}
+		payloads = append(payloads, string(publish.Payload))
+	}
+
+	expected := []string{beacon.PresenceOnline, beacon.PresenceOffline}
+	if !slices.Equal(payloads, expected) {
+		t.Fatalf("Test failed! Expected payloads: %v, got: %v", expected, payloads)
+	}
+}
+
+func Test_MQTTBroker_Reconnect(t *testing.T) {
+	server := newMQTTTestServer()
+	broker := newTestMQTTBroker(server)
+
+	events := make(chan beacon.ConnectionEvent, 16)
+	broker.OnConnectionStateChange(func(event beacon.ConnectionEvent) {
+		events <- event
+	})
+
+	if err := broker.Connect(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer broker.Disconnect()
+
+	subTopic, _ := beacon.NewTopic("foo/{foo_id}")
+	messageChan, _ := broker.Subscribe(subTopic)
+
+	waitForState := func(state beacon.ConnectionState) beacon.ConnectionEvent {
+		t.Helper()
+
+		for {
+			select {
+			case event := <-events:
+				if event.State == state {
+					return event
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("Test failed! Timed out waiting for state: %s", state)
+			}
+		}
+	}
+
+	waitForState(beacon.ConnectionConnected)
+
+	server.drop()
+
+	waitForState(beacon.ConnectionLost)
+	if event := waitForState(beacon.ConnectionConnected); event.Err != nil {
+		t.Fatalf("Test failed! Unexpected error restoring subscriptions: %v", event.Err)
+	}
+
+	if server.connectCount() != 2 {
+		t.Fatalf("Test failed! Expected %d connections, got: %d", 2, server.connectCount())
+	}
+
+	if filters := server.filters(); !slices.Equal(filters, []string{"foo/+"}) {
+		t.Fatalf("Test failed! Expected filters: %v, got: %v", []string{"foo/+"}, filters)
+	}
+
+	pubTopic, _ := beacon.NewTopic("foo/1")
+	if err := broker.Publish(pubTopic, beacon.Message{Payload: []byte("after reconnect")}); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if received := receive(t, messageChan); string(received.Payload) != "after reconnect" {
+		t.Fatalf("Test failed! Expected payload: %s, got: %s", "after reconnect", received.Payload)
+	}
+}
@@ -0,0 +1,43 @@
+package beacon
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type Codec interface {
+	// ContentType returns the MIME type of the encoded payloads, e.g. "application/json".
+	ContentType() string
+
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// DecodeError is returned by typed handlers when the payload of a message can not be decoded.
+type DecodeError struct {
+	Topic       string
+	ContentType string
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("could not decode %s payload from topic %s: %v", e.ContentType, e.Topic, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
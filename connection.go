@@ -0,0 +1,36 @@
+package beacon
+
+type ConnectionState int
+
+const (
+	// ConnectionConnected is reported when the connection is established, including after a reconnection.
+	ConnectionConnected ConnectionState = iota
+	// ConnectionLost is reported when an established connection drops unexpectedly.
+	ConnectionLost
+	// ConnectionReconnecting is reported before every reconnection attempt.
+	ConnectionReconnecting
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionConnected:
+		return "connected"
+	case ConnectionLost:
+		return "lost"
+	case ConnectionReconnecting:
+		return "reconnecting"
+	}
+
+	return "unknown"
+}
+
+type ConnectionEvent struct {
+	State ConnectionState
+
+	// Cause of a lost connection, or why subscriptions could not be restored after connecting.
+	Err error
+}
+
+// ConnectionStateHandler is called whenever the state of a connection changes. It may be called from
+// any goroutine, so it must not block.
+type ConnectionStateHandler func(event ConnectionEvent)
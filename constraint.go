@@ -0,0 +1,71 @@
+package beacon
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type paramConstraint struct {
+	raw   string
+	name  string
+	check func(string) bool
+}
+
+// parseParamConstraint parses the constraint of a typed param, i.e. the part after ':' in {id:int}.
+// Supported constraints are int, uuid, regex(<pattern>) and enum(<value>|<value>|...).
+func parseParamConstraint(raw string) (paramConstraint, error) {
+	raw = strings.Trim(raw, " ")
+
+	constraint, err := newParamConstraint(raw)
+	constraint.raw = raw
+	return constraint, err
+}
+
+func newParamConstraint(raw string) (paramConstraint, error) {
+	switch {
+	case raw == "int":
+		return paramConstraint{
+			name: "an int",
+			check: func(value string) bool {
+				_, err := strconv.Atoi(value)
+				return err == nil
+			},
+		}, nil
+	case raw == "uuid":
+		return paramConstraint{
+			name:  "a uuid",
+			check: uuidPattern.MatchString,
+		}, nil
+	case strings.HasPrefix(raw, "regex(") && strings.HasSuffix(raw, ")"):
+		pattern := raw[len("regex(") : len(raw)-1]
+
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return paramConstraint{}, fmt.Errorf("%w: %w", ErrInvalidParamConstraint, err)
+		}
+
+		return paramConstraint{
+			name:  "matching " + pattern,
+			check: re.MatchString,
+		}, nil
+	case strings.HasPrefix(raw, "enum(") && strings.HasSuffix(raw, ")"):
+		values := strings.Split(raw[len("enum("):len(raw)-1], "|")
+		if slices.Contains(values, "") {
+			return paramConstraint{}, fmt.Errorf("%w: enum has an empty value", ErrInvalidParamConstraint)
+		}
+
+		return paramConstraint{
+			name: "one of " + strings.Join(values, ", "),
+			check: func(value string) bool {
+				return slices.Contains(values, value)
+			},
+		}, nil
+	}
+
+	return paramConstraint{}, fmt.Errorf("%w: unknown constraint %q", ErrInvalidParamConstraint, raw)
+}
@@ -0,0 +1,56 @@
+package beacon
+
+import (
+	"maps"
+	"strconv"
+)
+
+// Headers added to messages republished to a dead-letter topic.
+const (
+	HeaderDeadLetterError         = "x-dead-letter-error"
+	HeaderDeadLetterAttempts      = "x-dead-letter-attempts"
+	HeaderDeadLetterOriginalTopic = "x-dead-letter-original-topic"
+)
+
+// DeadLetterTopicFunc returns the topic to which a permanently failed message is republished.
+type DeadLetterTopicFunc func(topic *TopicMatch) string
+
+// DeadLetterPrefix republishes failed messages to their original topic under prefix, e.g. "dlq/<original topic>".
+func DeadLetterPrefix(prefix string) DeadLetterTopicFunc {
+	return func(topic *TopicMatch) string {
+		return prefix + "/" + topic.FullName()
+	}
+}
+
+func (r *Router) deadLetter(topicFunc DeadLetterTopicFunc, message RoutedMessage, attempts int, cause error) {
+	// A dead-lettered message is received again by any subscription matching the dead-letter topic. If it
+	// fails there too, it is dropped instead of being dead-lettered again, which would loop forever.
+	if message.GetHeader(HeaderDeadLetterOriginalTopic) != "" {
+		r.logger.Error("Dropped failed message that was already dead-lettered.", "topic", message.Topic.FullName(), "original_topic", message.GetHeader(HeaderDeadLetterOriginalTopic), "error", cause)
+		return
+	}
+
+	topicName, ok := r.deadLetterTopicName(topicFunc, message)
+	if !ok {
+		return
+	}
+
+	topic, err := NewTopic(topicName)
+	if err != nil {
+		r.logger.Error("Invalid dead-letter topic.", "topic", message.Topic.FullName(), "error", err)
+		return
+	}
+
+	deadLetter := message.Message
+	deadLetter.Headers = maps.Clone(message.Headers)
+	deadLetter.SetHeader(HeaderDeadLetterError, cause.Error())
+	deadLetter.SetHeader(HeaderDeadLetterAttempts, strconv.Itoa(attempts))
+	deadLetter.SetHeader(HeaderDeadLetterOriginalTopic, message.Topic.FullName())
+
+	if err := r.broker.Publish(topic, deadLetter); err != nil {
+		r.logger.Error("Error publishing message to dead-letter topic.", "topic", message.Topic.FullName(), "dead_letter_topic", topic.Raw(), "error", err)
+		return
+	}
+
+	r.logger.Info("Published message to dead-letter topic.", "topic", message.Topic.FullName(), "dead_letter_topic", topic.Raw())
+}
@@ -0,0 +1,132 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Router_DeadLetter(t *testing.T) {
+	type testCase struct {
+		routerOptions    []OptionFunc
+		options          []SubscriptionOption
+		payload          string
+		handlerErr       error
+		expectedTopic    string
+		expectedAttempts string
+	}
+
+	tests := map[string]testCase{
+		"Retries exhausted": {
+			routerOptions: []OptionFunc{
+				WithDeadLetter(DeadLetterPrefix("dlq")),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+			},
+			handlerErr:       errors.New("failure"),
+			expectedTopic:    "dlq/foo/1",
+			expectedAttempts: "2",
+		},
+		"Permanent error": {
+			routerOptions: []OptionFunc{
+				WithDeadLetter(DeadLetterPrefix("dlq")),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+			},
+			handlerErr:       Permanent(errors.New("failure")),
+			expectedTopic:    "dlq/foo/1",
+			expectedAttempts: "1",
+		},
+		"Subscription dead-letter topic": {
+			routerOptions: []OptionFunc{WithDeadLetter(DeadLetterPrefix("dlq"))},
+			options: []SubscriptionOption{WithSubscriptionDeadLetter(func(topic *TopicMatch) string {
+				return "failed/" + topic.Params()["foo_id"]
+			})},
+			handlerErr:       errors.New("failure"),
+			expectedTopic:    "failed/1",
+			expectedAttempts: "1",
+		},
+		"No dead-letter topic": {
+			handlerErr: errors.New("failure"),
+		},
+		"Handler succeeds": {
+			routerOptions: []OptionFunc{WithDeadLetter(DeadLetterPrefix("dlq"))},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport := newFakeTransport()
+			r := newTestRouter(transport, test.routerOptions...)
+
+			_ = r.AddSubscription("foo/{foo_id}", func(_ Publisher, _ RoutedMessage) error {
+				return test.handlerErr
+			}, test.options...)
+
+			if err := r.Start(); err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			message := Message{Payload: []byte("payload")}
+			message.SetHeader("correlation-id", "abc")
+			transport.deliverWithParams("foo/{foo_id}", "foo/1", map[string]string{"foo_id": "1"}, message)
+
+			time.Sleep(50 * time.Millisecond)
+			_ = r.Shutdown(context.Background())
+
+			if test.expectedTopic == "" {
+				if len(transport.published) != 0 {
+					t.Fatalf("Test failed! Expected no published messages, got: %v", transport.publishedTopics)
+				}
+				return
+			}
+
+			if len(transport.published) != 1 {
+				t.Fatalf("Test failed! Expected one published message, got: %d", len(transport.published))
+			}
+
+			got := transport.published[0]
+			if transport.publishedTopics[0] != test.expectedTopic {
+				t.Fatalf("Test failed! Expected topic: %s, got: %s", test.expectedTopic, transport.publishedTopics[0])
+			}
+			if string(got.Payload) != "payload" || got.GetHeader("correlation-id") != "abc" {
+				t.Fatalf("Test failed! Expected original message, got: %v", got)
+			}
+			if got.GetHeader(HeaderDeadLetterAttempts) != test.expectedAttempts {
+				t.Fatalf("Test failed! Expected attempts: %s, got: %s", test.expectedAttempts, got.GetHeader(HeaderDeadLetterAttempts))
+			}
+			if got.GetHeader(HeaderDeadLetterError) != "failure" {
+				t.Fatalf("Test failed! Expected error: %s, got: %s", "failure", got.GetHeader(HeaderDeadLetterError))
+			}
+			if got.GetHeader(HeaderDeadLetterOriginalTopic) != "foo/1" {
+				t.Fatalf("Test failed! Expected original topic: %s, got: %s", "foo/1", got.GetHeader(HeaderDeadLetterOriginalTopic))
+			}
+			if message.GetHeader(HeaderDeadLetterError) != "" {
+				t.Fatal("Test failed! Original message headers were modified.")
+			}
+		})
+	}
+}
+
+func Test_Router_DeadLetterAlreadyDeadLettered(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport, WithDeadLetter(DeadLetterPrefix("dlq")))
+
+	_ = r.AddSubscription("{device}/*", func(_ Publisher, _ RoutedMessage) error {
+		return errors.New("failure")
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	message := Message{Payload: []byte("payload")}
+	message.SetHeader(HeaderDeadLetterOriginalTopic, "foo/1")
+	transport.deliverWithParams("{device}/*", "dlq/foo/1", map[string]string{"device": "dlq"}, message)
+
+	time.Sleep(50 * time.Millisecond)
+	_ = r.Shutdown(context.Background())
+
+	if len(transport.published) != 0 {
+		t.Fatalf("Test failed! Expected no published messages, got: %v", transport.publishedTopics)
+	}
+}
@@ -0,0 +1,66 @@
+package beacon
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidEnvelope = errors.New("payload is not a beacon envelope")
+
+const envelopeVersion = 1
+
+// envelope is the wire format used by transports that can not carry message metadata natively,
+// such as MQTT 3.1.1, which has no user properties.
+type envelope struct {
+	// Identifies the payload as an envelope, so that plain JSON payloads are not mistaken for one.
+	Version int `json:"beacon_envelope"`
+
+	ID          string            `json:"id,omitempty"`
+	Timestamp   *time.Time        `json:"timestamp,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Payload     []byte            `json:"payload"`
+}
+
+// MarshalEnvelope encodes a message, including its metadata, into a single payload.
+func MarshalEnvelope(message Message) ([]byte, error) {
+	e := envelope{
+		Version:     envelopeVersion,
+		ID:          message.ID,
+		ContentType: message.ContentType,
+		Headers:     message.Headers,
+		Payload:     message.Payload,
+	}
+
+	if !message.Timestamp.IsZero() {
+		e.Timestamp = &message.Timestamp
+	}
+
+	return json.Marshal(e)
+}
+
+// UnmarshalEnvelope decodes a payload produced by MarshalEnvelope.
+func UnmarshalEnvelope(data []byte) (Message, error) {
+	var e envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Message{}, err
+	}
+
+	if e.Version != envelopeVersion {
+		return Message{}, ErrInvalidEnvelope
+	}
+
+	message := Message{
+		ID:          e.ID,
+		ContentType: e.ContentType,
+		Headers:     e.Headers,
+		Payload:     e.Payload,
+	}
+
+	if e.Timestamp != nil {
+		message.Timestamp = *e.Timestamp
+	}
+
+	return message, nil
+}
@@ -0,0 +1,52 @@
+package beacon
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Envelope(t *testing.T) {
+	type testCase struct {
+		message Message
+	}
+
+	tests := map[string]testCase{
+		"Payload only": {
+			message: Message{Payload: []byte("hello")},
+		},
+		"With metadata": {
+			message: Message{
+				ID:          "1",
+				Timestamp:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				ContentType: "application/json",
+				Headers:     map[string]string{HeaderCorrelationID: "abc"},
+				Payload:     []byte(`{"value":42}`),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := MarshalEnvelope(test.message)
+			if err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			got, err := UnmarshalEnvelope(data)
+			if err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.message, got) {
+				t.Fatalf("Test failed! Expected: %v, got: %v", test.message, got)
+			}
+		})
+	}
+}
+
+func Test_UnmarshalEnvelope_NotAnEnvelope(t *testing.T) {
+	if _, err := UnmarshalEnvelope([]byte(`{"payload":"aGVsbG8="}`)); err != ErrInvalidEnvelope {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidEnvelope, err)
+	}
+}
@@ -9,8 +9,7 @@ import (
 	"time"
 
 	"github.com/pmoura-dev/beacon"
-	"github.com/pmoura-dev/beacon/publishers"
-	"github.com/pmoura-dev/beacon/subscribers"
+	"github.com/pmoura-dev/beacon/brokers"
 )
 
 func fooHandler(publisher beacon.Publisher, message beacon.RoutedMessage) error {
@@ -46,11 +45,11 @@ func main() {
 
 	mqttURL := "mqtt://broker.emqx.io:1883"
 
-	subscriber := subscribers.NewMQTTSubscriber(mqttURL)
-	publisher := publishers.NewMQTTPublisher(mqttURL)
+	// A single MQTT connection is used for both subscribing and publishing.
+	mqttBroker := brokers.NewMQTTBroker(mqttURL)
 
 	r := beacon.NewRouter(
-		beacon.NewBroker(subscriber, publisher),
+		beacon.NewBroker(mqttBroker, mqttBroker),
 	)
 
 	_ = r.AddSubscription("foo/{foo_id}/topic", fooHandler)
@@ -0,0 +1,98 @@
+package beacon
+
+import (
+	"context"
+	"strings"
+)
+
+// Group registers subscriptions under a shared topic prefix. Middleware added to a group only applies to
+// the handlers of that group and its nested groups, and runs inside the router's middleware
+// and outside the middleware of the subscription itself.
+type Group struct {
+	router *Router
+	parent subscriptionRegistry
+	prefix string
+
+	middlewareChain ContextMiddleware
+}
+
+type subscriptionRegistry interface {
+	AddContextSubscription(rawTopic string, handler ContextHandlerFunc, options ...SubscriptionOption) error
+	RemoveSubscription(rawTopic string) error
+}
+
+func (r *Router) Group(prefix string) *Group {
+	return &Group{
+		router:          r,
+		parent:          r,
+		prefix:          prefix,
+		middlewareChain: identityMiddleware,
+	}
+}
+
+// Group returns a nested group whose prefix is appended to the prefix of g.
+func (g *Group) Group(prefix string) *Group {
+	return &Group{
+		router:          g.router,
+		parent:          g,
+		prefix:          prefix,
+		middlewareChain: identityMiddleware,
+	}
+}
+
+func (g *Group) AddSubscription(rawTopic string, handler HandlerFunc, options ...SubscriptionOption) error {
+	return g.AddContextSubscription(rawTopic, FromHandlerFunc(handler), options...)
+}
+
+func (g *Group) AddContextSubscription(rawTopic string, handler ContextHandlerFunc, options ...SubscriptionOption) error {
+	// The subscription's own middleware runs inside the group's middleware, so it is applied here instead of
+	// when the subscription is created.
+	handler, options = extractMiddleware(handler, options)
+
+	wrapped := func(ctx context.Context, publisher Publisher, message RoutedMessage) error {
+		return g.middlewareChain(handler)(ctx, publisher, message)
+	}
+
+	return g.parent.AddContextSubscription(joinTopic(g.prefix, rawTopic), wrapped, options...)
+}
+
+func (g *Group) RemoveSubscription(rawTopic string) error {
+	return g.parent.RemoveSubscription(joinTopic(g.prefix, rawTopic))
+}
+
+func (g *Group) UseMiddleware(middleware Middleware) error {
+	return g.UseContextMiddleware(FromMiddleware(middleware))
+}
+
+func (g *Group) UseContextMiddleware(middleware ContextMiddleware) error {
+	g.router.mu.Lock()
+	defer g.router.mu.Unlock()
+
+	if g.router.isRunning {
+		g.router.logger.Error("Middleware could not be added. Router is already running.", "prefix", g.prefix)
+		return ErrCannotAddMiddleware
+	}
+
+	prev := g.middlewareChain
+
+	g.middlewareChain = func(next ContextHandlerFunc) ContextHandlerFunc {
+		return middleware(prev(next))
+	}
+
+	return nil
+}
+
+func joinTopic(prefix string, rawTopic string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	rawTopic = strings.TrimPrefix(rawTopic, "/")
+
+	if prefix == "" {
+		return rawTopic
+	}
+
+	if rawTopic == "" {
+		return prefix
+	}
+
+	return prefix + "/" + rawTopic
+}
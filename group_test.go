@@ -0,0 +1,175 @@
+package beacon
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_joinTopic(t *testing.T) {
+	type testCase struct {
+		prefix   string
+		rawTopic string
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"Simple": {
+			prefix:   "home/{home_id}",
+			rawTopic: "rooms/{room_id}",
+			expected: "home/{home_id}/rooms/{room_id}",
+		},
+		"Redundant slashes": {
+			prefix:   "home/{home_id}/",
+			rawTopic: "/rooms",
+			expected: "home/{home_id}/rooms",
+		},
+		"Empty prefix": {
+			prefix:   "",
+			rawTopic: "rooms",
+			expected: "rooms",
+		},
+		"Empty topic": {
+			prefix:   "home/{home_id}",
+			rawTopic: "",
+			expected: "home/{home_id}",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := joinTopic(test.prefix, test.rawTopic)
+
+			if got != test.expected {
+				t.Fatalf("Test failed! Expected: %s, got: %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func Test_Group(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) ContextMiddleware {
+		return func(next ContextHandlerFunc) ContextHandlerFunc {
+			return func(ctx context.Context, publisher Publisher, message RoutedMessage) error {
+				mu.Lock()
+				calls = append(calls, name+":"+message.Topic.FullName())
+				mu.Unlock()
+				return next(ctx, publisher, message)
+			}
+		}
+	}
+
+	handled := make(chan struct{}, 3)
+	handler := func(_ Publisher, _ RoutedMessage) error {
+		handled <- struct{}{}
+		return nil
+	}
+
+	_ = r.UseContextMiddleware(record("router"))
+
+	home := r.Group("home/{home_id}")
+	_ = home.UseContextMiddleware(record("home"))
+	_ = home.AddSubscription("state", handler)
+
+	rooms := home.Group("rooms/{room_id}")
+	_ = rooms.UseContextMiddleware(record("rooms"))
+	_ = rooms.AddSubscription("state", handler)
+
+	_ = r.AddSubscription("other", handler)
+
+	if err := rooms.AddSubscription("{home_id}", handler); err != ErrDuplicatedSingleLevelWildcard {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrDuplicatedSingleLevelWildcard, err)
+	}
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	for _, rawTopic := range []string{"home/{home_id}/state", "home/{home_id}/rooms/{room_id}/state", "other"} {
+		if !transport.subscribed(rawTopic) {
+			t.Fatalf("Test failed! Expected subscription to %s", rawTopic)
+		}
+	}
+
+	transport.deliver("home/{home_id}/rooms/{room_id}/state", "room", Message{})
+	<-handled
+	transport.deliver("home/{home_id}/state", "home", Message{})
+	<-handled
+	transport.deliver("other", "other", Message{})
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Message was not handled.")
+	}
+
+	expected := []string{
+		"router:room", "home:room", "rooms:room",
+		"router:home", "home:home",
+		"router:other",
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !slices.Equal(calls, expected) {
+		t.Fatalf("Test failed! Expected: %v, got: %v", expected, calls)
+	}
+}
+
+func Test_Group_SubscriptionMiddleware(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(publisher Publisher, message RoutedMessage) error {
+				calls = append(calls, name)
+				return next(publisher, message)
+			}
+		}
+	}
+
+	handled := make(chan struct{})
+	handler := func(_ Publisher, _ RoutedMessage) error {
+		calls = append(calls, "handler")
+		close(handled)
+		return nil
+	}
+
+	_ = r.UseMiddleware(record("router"))
+
+	home := r.Group("home/{home_id}")
+	_ = home.UseMiddleware(record("home"))
+
+	rooms := home.Group("rooms/{room_id}")
+	_ = rooms.UseMiddleware(record("rooms"))
+	_ = rooms.AddSubscription("state", handler, WithMiddleware(record("route1"), record("route2")))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	transport.deliver("home/{home_id}/rooms/{room_id}/state", "room", Message{})
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Message was not handled.")
+	}
+
+	expected := []string{"router", "home", "rooms", "route1", "route2", "handler"}
+	if !slices.Equal(calls, expected) {
+		t.Fatalf("Test failed! Expected: %v, got: %v", expected, calls)
+	}
+}
@@ -0,0 +1,254 @@
+package mqttconn
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pmoura-dev/beacon"
+	"github.com/pmoura-dev/beacon/internal/mqtttopic"
+	"github.com/pmoura-dev/beacon/mqttclient"
+)
+
+var (
+	ErrResubscribe = errors.New("failed to restore subscription")
+)
+
+type Config struct {
+	QOS                  byte
+	DisconnectionTimeout uint // milliseconds
+
+	// Whether messages are wrapped in an envelope carrying their metadata.
+	Envelope bool
+
+	ClientOptions []mqttclient.Option
+}
+
+// Conn is a single MQTT client connection on which topics can be both subscribed and published to.
+type Conn struct {
+	client    mqtt.Client
+	clientErr error // error building the client options, returned by Connect
+	config    Config
+
+	subscriptions *Subscriptions
+
+	stateHandlers   []beacon.ConnectionStateHandler
+	stateHandlersMu sync.Mutex
+}
+
+func New(url string, config Config) *Conn {
+	c := &Conn{
+		config:        config,
+		subscriptions: NewSubscriptions(),
+	}
+
+	opts, err := mqttclient.NewClientOptions(url, config.ClientOptions...)
+	if err != nil {
+		c.clientErr = err
+		opts, _ = mqttclient.NewClientOptions(url)
+	}
+
+	c.hookConnectionState(opts)
+	c.client = mqtt.NewClient(opts)
+
+	return c
+}
+
+func (c *Conn) Connect() error {
+	if c.clientErr != nil {
+		return c.clientErr
+	}
+
+	// The connection may be shared by a subscriber and a publisher, both connected by beacon.Broker.
+	if c.client.IsConnectionOpen() {
+		return nil
+	}
+
+	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+
+	return nil
+}
+
+func (c *Conn) Disconnect() error {
+	if !c.client.IsConnected() {
+		return nil
+	}
+
+	c.client.Disconnect(c.config.DisconnectionTimeout)
+	return nil
+}
+
+func (c *Conn) Subscribe(topic *beacon.Topic, options ...beacon.SubscribeOption) (<-chan beacon.RoutedMessage, error) {
+	opts, err := beacon.NewSubscribeOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := c.subscriptions.Add(topic, mqtttopic.SharedFilter(topic, opts.Group), c.subscribe)
+	if err != nil {
+		return nil, err
+	}
+
+	return sub.Messages(), nil
+}
+
+func (c *Conn) Unsubscribe(topic *beacon.Topic) error {
+	return c.subscriptions.Remove(topic, func(filter string) error {
+		token := c.client.Unsubscribe(filter)
+		if token.Wait() && token.Error() != nil {
+			return token.Error()
+		}
+
+		return nil
+	})
+}
+
+// subscribe subscribes to a filter on the server. The client keeps a single callback per filter, which
+// delivers the messages to every subscription using it.
+func (c *Conn) subscribe(filter string) error {
+	handler := func(_ mqtt.Client, m mqtt.Message) {
+		c.subscriptions.Dispatch(filter, m.Topic(), c.toMessage(m.Payload()))
+	}
+
+	token := c.client.Subscribe(filter, c.config.QOS, handler)
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+
+	return nil
+}
+
+func (c *Conn) Publish(topic *beacon.Topic, message beacon.Message, options ...beacon.PublishOption) error {
+	if topic.HasWildcards() {
+		return beacon.ErrWildcardTopic
+	}
+
+	opts, err := beacon.NewPublishOptions(options...)
+	if err != nil {
+		return err
+	}
+
+	// MQTT 3.1.1 has neither message expiry nor priorities.
+	if opts.Expiry != 0 {
+		return fmt.Errorf("%w: MQTT 3.1.1 does not support message expiry", beacon.ErrUnsupportedPublishOption)
+	}
+	if opts.Priority != nil {
+		return fmt.Errorf("%w: MQTT does not support message priority", beacon.ErrUnsupportedPublishOption)
+	}
+
+	qos := c.config.QOS
+	if opts.QoS != nil {
+		qos = *opts.QoS
+	}
+
+	// MQTT 3.1.1 has no user properties, so unless the envelope is enabled only the payload is sent.
+	// Headers and the remaining message metadata are dropped, except for the reply topic of a request,
+	// without which the request would silently never be answered.
+	if !c.config.Envelope && message.GetHeader(beacon.HeaderReplyTo) != "" {
+		return fmt.Errorf("%w: request/reply over MQTT 3.1.1 requires the envelope", beacon.ErrUnsupportedPublishOption)
+	}
+
+	payload := message.Payload
+	if c.config.Envelope && !opts.Raw {
+		var err error
+		if payload, err = beacon.MarshalEnvelope(message); err != nil {
+			return err
+		}
+	}
+
+	token := c.client.Publish(topic.Raw(), qos, opts.Retain, payload)
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+
+	return nil
+}
+
+// OnConnectionStateChange registers a handler called whenever the state of the connection changes.
+func (c *Conn) OnConnectionStateChange(handler beacon.ConnectionStateHandler) {
+	c.stateHandlersMu.Lock()
+	defer c.stateHandlersMu.Unlock()
+
+	c.stateHandlers = append(c.stateHandlers, handler)
+}
+
+// hookConnectionState chains the connection handlers of the client options, keeping the ones that were
+// already configured, to notify the state handlers and restore subscriptions on reconnection.
+func (c *Conn) hookConnectionState(opts *mqtt.ClientOptions) {
+	onConnect := opts.OnConnect
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		if onConnect != nil {
+			onConnect(client)
+		}
+
+		c.notify(beacon.ConnectionEvent{State: beacon.ConnectionConnected, Err: c.resubscribe()})
+	})
+
+	onConnectionLost := opts.OnConnectionLost
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		if onConnectionLost != nil {
+			onConnectionLost(client, err)
+		}
+
+		c.notify(beacon.ConnectionEvent{State: beacon.ConnectionLost, Err: err})
+	})
+
+	onReconnecting := opts.OnReconnecting
+	opts.SetReconnectingHandler(func(client mqtt.Client, options *mqtt.ClientOptions) {
+		if onReconnecting != nil {
+			onReconnecting(client, options)
+		}
+
+		c.notify(beacon.ConnectionEvent{State: beacon.ConnectionReconnecting})
+	})
+}
+
+func (c *Conn) notify(event beacon.ConnectionEvent) {
+	c.stateHandlersMu.Lock()
+	handlers := slices.Clone(c.stateHandlers)
+	c.stateHandlersMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// resubscribe subscribes again to every active topic filter, since a broker does not keep the
+// subscriptions of a clean session once its connection drops.
+func (c *Conn) resubscribe() error {
+	var errs []error
+	for _, filter := range c.subscriptions.Filters() {
+		if err := c.subscribe(filter); err != nil {
+			errs = append(errs, fmt.Errorf("%w %q: %w", ErrResubscribe, filter, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Conn) toMessage(payload []byte) beacon.Message {
+	if c.config.Envelope {
+		if message, err := beacon.UnmarshalEnvelope(payload); err == nil {
+			return message
+		}
+	}
+
+	return beacon.Message{Payload: payload}
+}
+
+// extractParamsFromMQTTTopic matches the topic of a received message against the subscribed topic. Messages
+// of shared subscriptions carry the topic they were published on, but the $share/<group>/ prefix is
+// removed in case the broker keeps it.
+func extractParamsFromMQTTTopic(topic *beacon.Topic, mqttTopic string) *beacon.TopicMatch {
+	mqttTopic = mqtttopic.Unshare(mqttTopic)
+
+	if match, ok := topic.Match(mqttTopic); ok {
+		return match
+	}
+
+	return beacon.NewTopicMatch(mqttTopic, map[string]string{})
+}
@@ -0,0 +1,153 @@
+package mqttconn
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pmoura-dev/beacon"
+)
+
+func Test_extractParamsFromMQTTTopic(t *testing.T) {
+	type testCase struct {
+		rawTopic  string
+		mqttTopic string
+		expected  *beacon.TopicMatch
+	}
+
+	tests := map[string]testCase{
+		"Shared subscription - prefixed topic": {
+			rawTopic:  "foo/{foo_id}/topic",
+			mqttTopic: "$share/workers/foo/12345/topic",
+			expected: beacon.NewTopicMatch("foo/12345/topic", map[string]string{
+				"foo_id": "12345",
+			}),
+		},
+		"Simple - one segment": {
+			rawTopic:  "foo",
+			mqttTopic: "foo",
+			expected:  beacon.NewTopicMatch("foo", map[string]string{}),
+		},
+		"Simple - multiple segments": {
+			rawTopic:  "foo/bar/baz",
+			mqttTopic: "foo/bar/baz",
+			expected:  beacon.NewTopicMatch("foo/bar/baz", map[string]string{}),
+		},
+		"Single level wildcard - single": {
+			rawTopic:  "{foo_id}",
+			mqttTopic: "12345",
+			expected: beacon.NewTopicMatch("12345", map[string]string{
+				"foo_id": "12345",
+			}),
+		},
+		"Single level wildcard - beginning": {
+			rawTopic:  "{foo_id}/bar",
+			mqttTopic: "12345/bar",
+			expected: beacon.NewTopicMatch("12345/bar", map[string]string{
+				"foo_id": "12345",
+			}),
+		},
+		"Single level wildcard - middle": {
+			rawTopic:  "foo/{foo_id}/bar",
+			mqttTopic: "foo/12345/bar",
+			expected: beacon.NewTopicMatch("foo/12345/bar", map[string]string{
+				"foo_id": "12345",
+			}),
+		},
+		"Single level wildcard - end": {
+			rawTopic:  "foo/{foo_id}",
+			mqttTopic: "foo/12345",
+			expected: beacon.NewTopicMatch("foo/12345", map[string]string{
+				"foo_id": "12345",
+			}),
+		},
+		"Single level wildcard - multiple": {
+			rawTopic:  "foo/{foo_id}/bar/{bar_id}",
+			mqttTopic: "foo/12345/bar/abcde",
+			expected: beacon.NewTopicMatch("foo/12345/bar/abcde", map[string]string{
+				"foo_id": "12345",
+				"bar_id": "abcde",
+			}),
+		},
+		"Multi level wildcard - root": {
+			rawTopic:  "*",
+			mqttTopic: "random/segment",
+			expected:  beacon.NewTopicMatch("random/segment", map[string]string{}),
+		},
+		"Multi level wildcard - end": {
+			rawTopic:  "foo/*",
+			mqttTopic: "foo/random/segment",
+			expected:  beacon.NewTopicMatch("foo/random/segment", map[string]string{}),
+		},
+		"Typed single level wildcard": {
+			rawTopic:  "foo/{foo_id:regex([a-z]{3})}/bar",
+			mqttTopic: "foo/abc/bar",
+			expected: beacon.NewTopicMatch("foo/abc/bar", map[string]string{
+				"foo_id": "abc",
+			}),
+		},
+		"Named multi level wildcard": {
+			rawTopic:  "files/{foo_id}/{path*}",
+			mqttTopic: "files/12345/random/segment",
+			expected: beacon.NewTopicMatch("files/12345/random/segment", map[string]string{
+				"foo_id": "12345",
+				"path":   "random/segment",
+			}),
+		},
+		"Multi level wildcard - with single level wildcard before": {
+			rawTopic:  "foo/{foo_id}/*",
+			mqttTopic: "foo/12345/random/segment",
+			expected: beacon.NewTopicMatch("foo/12345/random/segment", map[string]string{
+				"foo_id": "12345",
+			}),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			topic, _ := beacon.NewTopic(test.rawTopic)
+
+			got := extractParamsFromMQTTTopic(topic, test.mqttTopic)
+
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("Test failed! Expected: %s, got: %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func Test_hookConnectionState(t *testing.T) {
+	conn := New("mqtt://localhost:1883", Config{})
+
+	var events []beacon.ConnectionEvent
+	conn.OnConnectionStateChange(func(event beacon.ConnectionEvent) {
+		events = append(events, event)
+	})
+
+	// Handlers already set on the client options must keep being called.
+	var lostCalls int
+	opts := mqtt.NewClientOptions()
+	opts.SetConnectionLostHandler(func(mqtt.Client, error) {
+		lostCalls++
+	})
+	conn.hookConnectionState(opts)
+
+	lostErr := errors.New("connection reset")
+	opts.OnConnectionLost(nil, lostErr)
+	opts.OnReconnecting(nil, opts)
+	opts.OnConnect(nil)
+
+	expected := []beacon.ConnectionEvent{
+		{State: beacon.ConnectionLost, Err: lostErr},
+		{State: beacon.ConnectionReconnecting},
+		{State: beacon.ConnectionConnected},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("Test failed! Expected events: %v, got: %v", expected, events)
+	}
+
+	if lostCalls != 1 {
+		t.Fatalf("Test failed! Expected the existing handler to be called once, got: %d", lostCalls)
+	}
+}
@@ -0,0 +1,217 @@
+package mqttconn
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/pmoura-dev/beacon"
+	"github.com/pmoura-dev/beacon/internal/mqtttopic"
+)
+
+// Subscriptions keeps track of the active subscriptions of an MQTT connection, so that received messages
+// are delivered to them and they are restored whenever the client reconnects. It is shared by the
+// MQTT 3.1.1 and v5 transports.
+//
+// Topics that only differ in their param names or constraints, e.g. "foo/{id}" and "foo/{id:int}", map
+// to the same MQTT topic filter. The server only knows about the filter, so it is subscribed to once and
+// its messages are delivered to every subscription using it.
+//
+// Each filter is also given an identifier, which MQTT v5 servers send back with the messages delivered
+// through it. Identifiers are never reused, so messages received for a removed filter are dropped.
+type Subscriptions struct {
+	byTopic  map[*beacon.Topic]*Subscription
+	byFilter map[string][]*Subscription
+	trie     *beacon.TopicTrie[*Subscription]
+	mu       sync.Mutex
+
+	identifiers    map[string]int
+	filtersByID    map[int]string
+	lastIdentifier int
+
+	// Serializes adding and removing subscriptions, which subscribe and unsubscribe filters on the server.
+	changeMu sync.Mutex
+}
+
+type Subscription struct {
+	topic       *beacon.Topic
+	filter      string
+	messageChan chan beacon.RoutedMessage
+
+	// Channel closed on removal to release deliveries blocked on the message channel.
+	done chan struct{}
+}
+
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{
+		byTopic:  make(map[*beacon.Topic]*Subscription),
+		byFilter: make(map[string][]*Subscription),
+		trie:     beacon.NewTopicTrie[*Subscription](),
+
+		identifiers: make(map[string]int),
+		filtersByID: make(map[int]string),
+	}
+}
+
+// Add registers the subscription of topic to the MQTT topic filter. If no other subscription uses the
+// filter, it is subscribed to on the server with subscribe, and the subscription is discarded if that fails.
+func (s *Subscriptions) Add(topic *beacon.Topic, filter string, subscribe func(filter string) error) (*Subscription, error) {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	sub := &Subscription{
+		topic:       topic,
+		filter:      filter,
+		messageChan: make(chan beacon.RoutedMessage),
+		done:        make(chan struct{}),
+	}
+
+	// The subscription is registered first so that retained messages sent right after subscribing are
+	// delivered to it.
+	s.mu.Lock()
+	first := len(s.byFilter[filter]) == 0
+	if first {
+		s.lastIdentifier++
+		s.identifiers[filter] = s.lastIdentifier
+		s.filtersByID[s.lastIdentifier] = filter
+	}
+	s.byTopic[topic] = sub
+	s.byFilter[filter] = append(s.byFilter[filter], sub)
+	s.trie.Insert(topic, sub)
+	s.mu.Unlock()
+
+	if !first {
+		return sub, nil
+	}
+
+	if err := subscribe(filter); err != nil {
+		s.remove(sub)
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// Remove ends the subscription made with topic, if any. Once no subscription uses its filter anymore, the
+// filter is unsubscribed from on the server with unsubscribe.
+func (s *Subscriptions) Remove(topic *beacon.Topic, unsubscribe func(filter string) error) error {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	s.mu.Lock()
+	sub, ok := s.byTopic[topic]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if last := s.remove(sub); !last {
+		return nil
+	}
+
+	return unsubscribe(sub.filter)
+}
+
+// remove removes a subscription and reports whether it was the last one using its filter.
+func (s *Subscriptions) remove(sub *Subscription) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.byTopic, sub.topic)
+	s.trie.Remove(sub.topic)
+	close(sub.done)
+
+	subscriptions := s.byFilter[sub.filter]
+	for i, other := range subscriptions {
+		if other == sub {
+			subscriptions = append(subscriptions[:i], subscriptions[i+1:]...)
+			break
+		}
+	}
+
+	if len(subscriptions) == 0 {
+		delete(s.byFilter, sub.filter)
+		delete(s.filtersByID, s.identifiers[sub.filter])
+		delete(s.identifiers, sub.filter)
+		return true
+	}
+
+	s.byFilter[sub.filter] = subscriptions
+	return false
+}
+
+// Filters returns the filters of the active subscriptions, each of them once.
+func (s *Subscriptions) Filters() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filters := make([]string, 0, len(s.byFilter))
+	for filter := range s.byFilter {
+		filters = append(filters, filter)
+	}
+
+	return filters
+}
+
+// Identifier returns the subscription identifier of filter, or 0 if no subscription uses it.
+func (s *Subscriptions) Identifier(filter string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.identifiers[filter]
+}
+
+// Dispatch delivers a message received on mqttTopic through filter to every subscription using the filter.
+func (s *Subscriptions) Dispatch(filter string, mqttTopic string, message beacon.Message) {
+	s.mu.Lock()
+	subscriptions := slices.Clone(s.byFilter[filter])
+	s.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.send(extractParamsFromMQTTTopic(sub.topic, mqttTopic), message)
+	}
+}
+
+// DispatchIdentifier delivers a message received on mqttTopic through the filter with the given subscription
+// identifier to every subscription using the filter. Messages of removed filters are dropped.
+func (s *Subscriptions) DispatchIdentifier(identifier int, mqttTopic string, message beacon.Message) {
+	s.mu.Lock()
+	filter, ok := s.filtersByID[identifier]
+	s.mu.Unlock()
+
+	if ok {
+		s.Dispatch(filter, mqttTopic, message)
+	}
+}
+
+// Route delivers a message received on mqttTopic to every subscription whose topic matches it. It is used
+// for messages without a subscription identifier, which can not be traced back to their filter.
+func (s *Subscriptions) Route(mqttTopic string, message beacon.Message) {
+	s.mu.Lock()
+	matches := s.trie.Match(mqtttopic.Unshare(mqttTopic))
+	s.mu.Unlock()
+
+	for _, match := range matches {
+		match.Value.send(match.Match, message)
+	}
+}
+
+func (sub *Subscription) Messages() <-chan beacon.RoutedMessage {
+	return sub.messageChan
+}
+
+// send blocks until the message is read or the subscription ends.
+func (sub *Subscription) send(match *beacon.TopicMatch, message beacon.Message) {
+	// Each subscription gets its own copy of the headers so handlers can modify them safely.
+	routed := beacon.RoutedMessage{
+		Message: message,
+		Topic:   match,
+	}
+	routed.Headers = maps.Clone(message.Headers)
+
+	select {
+	case sub.messageChan <- routed:
+	case <-sub.done:
+	}
+}
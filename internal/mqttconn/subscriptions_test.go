@@ -0,0 +1,105 @@
+package mqttconn
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pmoura-dev/beacon"
+)
+
+func Test_Subscriptions_SameFilter(t *testing.T) {
+	subscriptions := NewSubscriptions()
+
+	var subscribed, unsubscribed []string
+	subscribe := func(filter string) error {
+		subscribed = append(subscribed, filter)
+		return nil
+	}
+	unsubscribe := func(filter string) error {
+		unsubscribed = append(unsubscribed, filter)
+		return nil
+	}
+
+	// Both topics map to the filter "foo/+".
+	anyTopic, _ := beacon.NewTopic("foo/{foo_id}")
+	intTopic, _ := beacon.NewTopic("foo/{foo_id:int}")
+
+	anySub, _ := subscriptions.Add(anyTopic, "foo/+", subscribe)
+	intSub, _ := subscriptions.Add(intTopic, "foo/+", subscribe)
+
+	if !slices.Equal(subscribed, []string{"foo/+"}) {
+		t.Fatalf("Test failed! Expected subscribed filters: %v, got: %v", []string{"foo/+"}, subscribed)
+	}
+
+	if filters := subscriptions.Filters(); !slices.Equal(filters, []string{"foo/+"}) {
+		t.Fatalf("Test failed! Expected filters: %v, got: %v", []string{"foo/+"}, filters)
+	}
+
+	go subscriptions.Dispatch("foo/+", "foo/1", beacon.Message{})
+
+	for _, sub := range []*Subscription{anySub, intSub} {
+		if received := <-sub.Messages(); received.GetTopicParam("foo_id") != "1" {
+			t.Fatalf("Test failed! Expected foo_id: %s, got: %s", "1", received.GetTopicParam("foo_id"))
+		}
+	}
+
+	// The filter is kept on the server until its last subscription is removed.
+	_ = subscriptions.Remove(anyTopic, unsubscribe)
+	if len(unsubscribed) != 0 {
+		t.Fatalf("Test failed! Expected no unsubscribed filters, got: %v", unsubscribed)
+	}
+
+	go subscriptions.Dispatch("foo/+", "foo/2", beacon.Message{})
+
+	if received := <-intSub.Messages(); received.GetTopicParam("foo_id") != "2" {
+		t.Fatalf("Test failed! Expected foo_id: %s, got: %s", "2", received.GetTopicParam("foo_id"))
+	}
+
+	_ = subscriptions.Remove(intTopic, unsubscribe)
+	if !slices.Equal(unsubscribed, []string{"foo/+"}) {
+		t.Fatalf("Test failed! Expected unsubscribed filters: %v, got: %v", []string{"foo/+"}, unsubscribed)
+	}
+
+	if filters := subscriptions.Filters(); len(filters) != 0 {
+		t.Fatalf("Test failed! Expected no filters, got: %v", filters)
+	}
+}
+
+func Test_Subscriptions_Identifiers(t *testing.T) {
+	subscriptions := NewSubscriptions()
+	subscribe := func(string) error { return nil }
+	unsubscribe := func(string) error { return nil }
+
+	sharedTopic, _ := beacon.NewTopic("foo/{foo_id}")
+	allTopic, _ := beacon.NewTopic("foo/*")
+
+	sharedSub, _ := subscriptions.Add(sharedTopic, "$share/workers/foo/+", subscribe)
+	allSub, _ := subscriptions.Add(allTopic, "foo/#", subscribe)
+
+	sharedID := subscriptions.Identifier("$share/workers/foo/+")
+	allID := subscriptions.Identifier("foo/#")
+	if sharedID == 0 || allID == 0 || sharedID == allID {
+		t.Fatalf("Test failed! Expected distinct identifiers, got: %d and %d", sharedID, allID)
+	}
+
+	// Only the subscriptions of the identified filter receive the message.
+	go subscriptions.DispatchIdentifier(allID, "foo/1", beacon.Message{})
+
+	if received := <-allSub.Messages(); received.Topic.FullName() != "foo/1" {
+		t.Fatalf("Test failed! Expected topic: %s, got: %s", "foo/1", received.Topic.FullName())
+	}
+
+	select {
+	case <-sharedSub.Messages():
+		t.Fatal("Test failed! Expected no message for the shared subscription.")
+	default:
+	}
+
+	// Identifiers of removed filters are not reused.
+	_ = subscriptions.Remove(allTopic, unsubscribe)
+	_, _ = subscriptions.Add(allTopic, "foo/#", subscribe)
+
+	if id := subscriptions.Identifier("foo/#"); id == allID {
+		t.Fatalf("Test failed! Expected a new identifier, got: %d", id)
+	}
+}
@@ -0,0 +1,59 @@
+package mqtttopic
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pmoura-dev/beacon"
+)
+
+// Filter converts a topic into an MQTT topic filter, replacing single level wildcards with "+" and
+// multi-level wildcards with "#".
+func Filter(topic *beacon.Topic) string {
+	segments := slices.Clone(topic.Segments())
+
+	for i, s := range segments {
+		switch {
+		// Replace the named multi-level wildcard {param*} with "#"
+		case i == len(segments)-1 && topic.TailParam() != "":
+			segments[i] = "#"
+
+		// Replace named wildcards {param}, including typed ones such as {param:int}, with "+"
+		case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+			segments[i] = "+"
+
+		// Replace * with "#"
+		case strings.Trim(s, " ") == "*":
+			segments[i] = "#"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// sharePrefix starts the filters of MQTT shared subscriptions, e.g. $share/<group>/foo/+.
+const sharePrefix = "$share/"
+
+// SharedFilter converts a topic into an MQTT topic filter shared by the subscribers in group. If group is
+// empty, the filter is not shared.
+func SharedFilter(topic *beacon.Topic, group string) string {
+	if group == "" {
+		return Filter(topic)
+	}
+
+	return sharePrefix + group + "/" + Filter(topic)
+}
+
+// Unshare removes the $share/<group>/ prefix of a shared subscription filter, if any.
+func Unshare(filter string) string {
+	shared, ok := strings.CutPrefix(filter, sharePrefix)
+	if !ok {
+		return filter
+	}
+
+	if _, unshared, ok := strings.Cut(shared, "/"); ok {
+		return unshared
+	}
+
+	return filter
+}
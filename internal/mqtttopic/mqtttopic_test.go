@@ -0,0 +1,106 @@
+package mqtttopic
+
+import (
+	"testing"
+
+	"github.com/pmoura-dev/beacon"
+)
+
+func Test_Filter(t *testing.T) {
+	type testCase struct {
+		topic    string
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"Simple - one segment": {
+			topic:    "foo",
+			expected: "foo",
+		},
+		"Simple - multiple segments": {
+			topic:    "foo/bar/baz",
+			expected: "foo/bar/baz",
+		},
+		"Single level wildcard - single": {
+			topic:    "{foo_id}",
+			expected: "+",
+		},
+		"Single level wildcard - beginning": {
+			topic:    "{foo_id}/bar",
+			expected: "+/bar",
+		},
+		"Single level wildcard - middle": {
+			topic:    "foo/{foo_id}/bar",
+			expected: "foo/+/bar",
+		},
+		"Single level wildcard - end": {
+			topic:    "foo/{foo_id}",
+			expected: "foo/+",
+		},
+		"Single level wildcard - multiple": {
+			topic:    "foo/{foo_id}/bar/{bar_id}",
+			expected: "foo/+/bar/+",
+		},
+		"Multi level wildcard - root": {
+			topic:    "*",
+			expected: "#",
+		},
+		"Multi level wildcard - end": {
+			topic:    "foo/*",
+			expected: "foo/#",
+		},
+		"Multi level wildcard - with single level wildcard before": {
+			topic:    "foo/{foo_id}/*",
+			expected: "foo/+/#",
+		},
+		"Typed single level wildcard": {
+			topic:    "foo/{foo_id:regex([a-z]{3})}/bar",
+			expected: "foo/+/bar",
+		},
+		"Named multi level wildcard": {
+			topic:    "files/{foo_id}/{path*}",
+			expected: "files/+/#",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			topic, _ := beacon.NewTopic(test.topic)
+
+			got := Filter(topic)
+
+			if test.expected != got {
+				t.Fatalf("Test failed! Expected: %s, got: %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func Test_SharedFilter(t *testing.T) {
+	topic, _ := beacon.NewTopic("foo/{foo_id}/topic")
+
+	if filter := SharedFilter(topic, "workers"); filter != "$share/workers/foo/+/topic" {
+		t.Fatalf("Test failed! Expected filter: %s, got: %s", "$share/workers/foo/+/topic", filter)
+	}
+
+	if filter := SharedFilter(topic, ""); filter != "foo/+/topic" {
+		t.Fatalf("Test failed! Expected filter: %s, got: %s", "foo/+/topic", filter)
+	}
+}
+
+func Test_Unshare(t *testing.T) {
+	tests := map[string]string{
+		"$share/workers/foo/+/topic": "foo/+/topic",
+		"$share/workers/#":           "#",
+		"foo/+/topic":                "foo/+/topic",
+		"$share/workers":             "$share/workers",
+	}
+
+	for filter, expected := range tests {
+		t.Run(filter, func(t *testing.T) {
+			if unshared := Unshare(filter); unshared != expected {
+				t.Fatalf("Test failed! Expected filter: %s, got: %s", expected, unshared)
+			}
+		})
+	}
+}
@@ -1,9 +1,35 @@
 package beacon
 
+import "time"
+
 type Message struct {
+	// Optional identifier of the message, e.g. used for deduplication or tracing.
+	ID string
+
+	// Time at which the message was produced.
+	Timestamp time.Time
+
+	// MIME type of the payload, e.g. "application/json".
+	ContentType string
+
+	// Arbitrary metadata such as correlation IDs or trace context.
+	Headers map[string]string
+
 	Payload []byte
 }
 
+func (m *Message) GetHeader(key string) string {
+	return m.Headers[key]
+}
+
+func (m *Message) SetHeader(key string, value string) {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+
+	m.Headers[key] = value
+}
+
 type RoutedMessage struct {
 	Message
 	Topic *TopicMatch
@@ -12,3 +38,8 @@ type RoutedMessage struct {
 func (m *RoutedMessage) GetTopicParam(param string) string {
 	return m.Topic.Params()[param]
 }
+
+// GetTopicParamInt returns the value of a topic param parsed as an integer.
+func (m *RoutedMessage) GetTopicParamInt(param string) (int, error) {
+	return m.Topic.ParamInt(param)
+}
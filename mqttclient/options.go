@@ -0,0 +1,136 @@
+package mqttclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var (
+	ErrInvalidCACertificate = errors.New("CA certificate file does not contain any valid certificate")
+)
+
+// Option configures the MQTT client of the MQTT transports, both for MQTT 3.1.1 and v5.
+type Option func(*mqtt.ClientOptions) error
+
+// NewClientOptions returns the client options used to connect to the broker at url.
+// Unless configured otherwise, the client uses a clean session.
+func NewClientOptions(url string, options ...Option) (*mqtt.ClientOptions, error) {
+	opts := mqtt.NewClientOptions().AddBroker(url)
+	opts.SetCleanSession(true)
+
+	for _, opt := range options {
+		if err := opt(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return opts, nil
+}
+
+func WithClientID(id string) Option {
+	return func(o *mqtt.ClientOptions) error {
+		o.SetClientID(id)
+		return nil
+	}
+}
+
+func WithCredentials(username string, password string) Option {
+	return func(o *mqtt.ClientOptions) error {
+		o.SetUsername(username)
+		o.SetPassword(password)
+		return nil
+	}
+}
+
+// WithCredentialsProvider sets a function called on every connection attempt to obtain the username and
+// password, which allows rotating credentials without recreating the client.
+func WithCredentialsProvider(provider func() (username string, password string)) Option {
+	return func(o *mqtt.ClientOptions) error {
+		o.SetCredentialsProvider(provider)
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to the broker. It replaces any previous TLS
+// configuration, so it must come before WithCACertFile and WithClientCertFile, which add to it.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *mqtt.ClientOptions) error {
+		o.SetTLSConfig(config.Clone())
+		return nil
+	}
+}
+
+// WithCACertFile trusts the PEM encoded CA certificates in path when verifying the broker's certificate.
+func WithCACertFile(path string) Option {
+	return func(o *mqtt.ClientOptions) error {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		config := tlsConfig(o)
+		if config.RootCAs == nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return ErrInvalidCACertificate
+		}
+
+		return nil
+	}
+}
+
+// WithClientCertFile authenticates the client with the PEM encoded certificate and key, for mutual TLS.
+func WithClientCertFile(certFile string, keyFile string) Option {
+	return func(o *mqtt.ClientOptions) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+
+		config := tlsConfig(o)
+		config.Certificates = append(config.Certificates, cert)
+		return nil
+	}
+}
+
+func WithKeepAlive(keepAlive time.Duration) Option {
+	return func(o *mqtt.ClientOptions) error {
+		o.SetKeepAlive(keepAlive)
+		return nil
+	}
+}
+
+// WithCleanSession sets whether the broker discards the session state, such as subscriptions and
+// pending messages, when the client disconnects.
+func WithCleanSession(clean bool) Option {
+	return func(o *mqtt.ClientOptions) error {
+		o.SetCleanSession(clean)
+		return nil
+	}
+}
+
+// WithWill sets the last will and testament, a message the broker publishes on the client's behalf when
+// its connection drops without a clean disconnect. Paired with beacon.WithPresence on the same topic and
+// beacon.PresenceOffline as payload, it lets presence be tracked even when a service crashes.
+func WithWill(topic string, payload []byte, qos byte, retained bool) Option {
+	return func(o *mqtt.ClientOptions) error {
+		o.SetBinaryWill(topic, payload, qos, retained)
+		return nil
+	}
+}
+
+// tlsConfig returns the TLS configuration of the client options, creating it if needed.
+func tlsConfig(o *mqtt.ClientOptions) *tls.Config {
+	if o.TLSConfig == nil {
+		o.SetTLSConfig(&tls.Config{})
+	}
+
+	return o.TLSConfig
+}
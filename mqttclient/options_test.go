@@ -0,0 +1,180 @@
+package mqttclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_NewClientOptions(t *testing.T) {
+	opts, err := NewClientOptions("mqtt://localhost:1883",
+		WithClientID("beacon-test"),
+		WithCredentials("user", "secret"),
+		WithKeepAlive(15*time.Second),
+		WithCleanSession(false),
+	)
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if opts.ClientID != "beacon-test" {
+		t.Fatalf("Test failed! Expected client ID: %s, got: %s", "beacon-test", opts.ClientID)
+	}
+	if opts.Username != "user" || opts.Password != "secret" {
+		t.Fatalf("Test failed! Expected credentials: %s/%s, got: %s/%s", "user", "secret", opts.Username, opts.Password)
+	}
+	if opts.KeepAlive != 15 {
+		t.Fatalf("Test failed! Expected keepalive: %d, got: %d", 15, opts.KeepAlive)
+	}
+	if opts.CleanSession {
+		t.Fatal("Test failed! Expected clean session to be disabled.")
+	}
+}
+
+func Test_NewClientOptions_Defaults(t *testing.T) {
+	opts, err := NewClientOptions("mqtt://localhost:1883")
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if !opts.CleanSession {
+		t.Fatal("Test failed! Expected clean session by default.")
+	}
+	if opts.TLSConfig != nil {
+		t.Fatal("Test failed! Expected no TLS configuration by default.")
+	}
+}
+
+func Test_WithCredentialsProvider(t *testing.T) {
+	calls := 0
+	opts, _ := NewClientOptions("mqtt://localhost:1883", WithCredentialsProvider(func() (string, string) {
+		calls++
+		return "user", "rotated"
+	}))
+
+	username, password := opts.CredentialsProvider()
+	if username != "user" || password != "rotated" || calls != 1 {
+		t.Fatalf("Test failed! Expected provider to be used, got: %s/%s after %d calls", username, password, calls)
+	}
+}
+
+func Test_WithWill(t *testing.T) {
+	opts, err := NewClientOptions("mqtt://localhost:1883", WithWill("services/foo/status", []byte("offline"), 1, true))
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if !opts.WillEnabled || opts.WillTopic != "services/foo/status" || string(opts.WillPayload) != "offline" {
+		t.Fatalf("Test failed! Expected will on %s with payload %s, got: %s with payload %s",
+			"services/foo/status", "offline", opts.WillTopic, opts.WillPayload)
+	}
+	if opts.WillQos != 1 || !opts.WillRetained {
+		t.Fatalf("Test failed! Expected will QoS %d and retained, got: QoS %d and retained %t", 1, opts.WillQos, opts.WillRetained)
+	}
+}
+
+func Test_TLSOptions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSignedCert(t, dir)
+
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	_ = os.WriteFile(invalidFile, []byte("not a certificate"), 0o600)
+
+	type testCase struct {
+		options     []Option
+		wantErr     bool
+		expectedErr error
+	}
+
+	tests := map[string]testCase{
+		"CA and client certificate": {
+			options: []Option{
+				WithTLSConfig(&tls.Config{ServerName: "broker"}),
+				WithCACertFile(certFile),
+				WithClientCertFile(certFile, keyFile),
+			},
+		},
+		"Error - Missing CA file": {
+			options: []Option{WithCACertFile(filepath.Join(dir, "missing.pem"))},
+			wantErr: true,
+		},
+		"Error - Invalid CA file": {
+			options:     []Option{WithCACertFile(invalidFile)},
+			wantErr:     true,
+			expectedErr: ErrInvalidCACertificate,
+		},
+		"Error - Invalid client certificate": {
+			options: []Option{WithClientCertFile(invalidFile, keyFile)},
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts, err := NewClientOptions("mqtts://localhost:8883", test.options...)
+
+			if test.wantErr {
+				if err == nil || (test.expectedErr != nil && err != test.expectedErr) {
+					t.Fatalf("Test failed! Expected error: %v, got: %v", test.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			if opts.TLSConfig.ServerName != "broker" {
+				t.Fatalf("Test failed! Expected server name: %s, got: %s", "broker", opts.TLSConfig.ServerName)
+			}
+			if opts.TLSConfig.RootCAs == nil {
+				t.Fatal("Test failed! Expected CA certificate to be trusted.")
+			}
+			if len(opts.TLSConfig.Certificates) != 1 {
+				t.Fatalf("Test failed! Expected one client certificate, got: %d", len(opts.TLSConfig.Certificates))
+			}
+		})
+	}
+}
+
+func writeSelfSignedCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Could not create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Could not marshal key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	_ = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	_ = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	return certFile, keyFile
+}
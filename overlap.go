@@ -0,0 +1,140 @@
+package beacon
+
+import (
+	"strings"
+)
+
+type OverlapPolicy int
+
+const (
+	// OverlapDeliverAll delivers a message to every subscription whose topic matches it.
+	OverlapDeliverAll OverlapPolicy = iota
+
+	// OverlapMostSpecific delivers a message only to the subscription with the most specific matching topic.
+	// Literal levels are more specific than typed params, which are more specific than untyped params,
+	// which are more specific than multi-level wildcards. Levels are compared from left to right.
+	OverlapMostSpecific
+
+	// OverlapReject refuses to add a subscription whose topic overlaps with an existing one.
+	OverlapReject
+)
+
+// Specificity ranks of a topic level, from most to least specific.
+const (
+	rankLiteral = iota
+	rankTypedParam
+	rankParam
+	rankEnd
+	rankMultiLevel
+)
+
+// Overlaps reports whether some concrete topic could match both topics. Param constraints are not considered.
+func (t *Topic) Overlaps(other *Topic) bool {
+	for i := 0; ; i++ {
+		done, otherDone := i >= len(t.segments), i >= len(other.segments)
+
+		if !done && t.isMultiLevel(i) || !otherDone && other.isMultiLevel(i) {
+			return true
+		}
+
+		if done || otherDone {
+			return done && otherDone
+		}
+
+		if t.segmentKind(i) == segmentLiteral && other.segmentKind(i) == segmentLiteral && t.segments[i] != other.segments[i] {
+			return false
+		}
+	}
+}
+
+// shape returns the structure of the topic regardless of its param names, so that for instance
+// "foo/{id}" and "foo/{foo_id}" have the same shape.
+func (t *Topic) shape() string {
+	segments := make([]string, len(t.segments))
+	paramIndex := 0
+	for i, s := range t.segments {
+		switch t.segmentKind(i) {
+		case segmentMultiLevel, segmentTail:
+			segments[i] = "*"
+		case segmentParam:
+			segments[i] = "{}"
+			if constraint, ok := t.constraints[t.params[paramIndex]]; ok {
+				segments[i] = "{:" + constraint.raw + "}"
+			}
+			paramIndex++
+		case segmentLiteral:
+			segments[i] = s
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (t *Topic) isMultiLevel(i int) bool {
+	kind := t.segmentKind(i)
+	return kind == segmentMultiLevel || kind == segmentTail
+}
+
+func (t *Topic) rank(i int) int {
+	if i >= len(t.segments) {
+		return rankEnd
+	}
+
+	switch t.segmentKind(i) {
+	case segmentMultiLevel, segmentTail:
+		return rankMultiLevel
+	case segmentParam:
+		if _, ok := t.constraints[t.paramAt(i)]; ok {
+			return rankTypedParam
+		}
+		return rankParam
+	}
+
+	return rankLiteral
+}
+
+// paramAt returns the name of the param defined by the segment at index i.
+func (t *Topic) paramAt(i int) string {
+	paramIndex := 0
+	for j := 0; j < i; j++ {
+		if t.segmentKind(j) == segmentParam {
+			paramIndex++
+		}
+	}
+
+	return t.params[paramIndex]
+}
+
+// compareSpecificity returns a negative number if t is more specific than other, a positive number if
+// it is less specific and zero if both are equally specific.
+func (t *Topic) compareSpecificity(other *Topic) int {
+	for i := 0; i < max(len(t.segments), len(other.segments)); i++ {
+		if diff := t.rank(i) - other.rank(i); diff != 0 {
+			return diff
+		}
+
+		if t.rank(i) == rankMultiLevel {
+			return 0
+		}
+	}
+
+	return 0
+}
+
+// isMostSpecific reports whether no other subscription matching the concrete topic is more specific than sub.
+func (r *Router) isMostSpecific(sub *subscription, concrete string) bool {
+	r.trieMu.RLock()
+	defer r.trieMu.RUnlock()
+
+	for _, match := range r.subscriptionTrie.Match(concrete) {
+		if match.Value == sub || match.Topic.Validate(match.Match) != nil {
+			continue
+		}
+
+		if match.Topic.compareSpecificity(sub.topic) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
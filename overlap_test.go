@@ -0,0 +1,247 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Topic_Overlaps(t *testing.T) {
+	type testCase struct {
+		a        string
+		b        string
+		expected bool
+	}
+
+	tests := map[string]testCase{
+		"Same literal topic": {
+			a:        "foo/bar",
+			b:        "foo/bar",
+			expected: true,
+		},
+		"Different literal topics": {
+			a: "foo/bar",
+			b: "foo/baz",
+		},
+		"Different lengths": {
+			a: "foo/bar",
+			b: "foo/bar/baz",
+		},
+		"Param and literal": {
+			a:        "foo/{id}",
+			b:        "foo/bar",
+			expected: true,
+		},
+		"Param and multi-level wildcard": {
+			a:        "foo/{id}",
+			b:        "foo/*",
+			expected: true,
+		},
+		"Multi-level wildcard matches parent level": {
+			a:        "foo/*",
+			b:        "foo",
+			expected: true,
+		},
+		"Named multi-level wildcard": {
+			a:        "files/{path*}",
+			b:        "files/a/b",
+			expected: true,
+		},
+		"Multi-level wildcard with different prefix": {
+			a: "foo/*",
+			b: "bar/{id}",
+		},
+		"Params in different positions": {
+			a:        "{a}/bar/baz",
+			b:        "foo/{b}/baz",
+			expected: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, _ := NewTopic(test.a)
+			b, _ := NewTopic(test.b)
+
+			if got := a.Overlaps(b); got != test.expected {
+				t.Fatalf("Test failed! Expected: %v, got: %v", test.expected, got)
+			}
+
+			if got := b.Overlaps(a); got != test.expected {
+				t.Fatalf("Test failed! Expected symmetric result: %v, got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func Test_Topic_compareSpecificity(t *testing.T) {
+	type testCase struct {
+		moreSpecific string
+		lessSpecific string
+	}
+
+	tests := map[string]testCase{
+		"Literal over param": {
+			moreSpecific: "foo/bar",
+			lessSpecific: "foo/{id}",
+		},
+		"Typed param over param": {
+			moreSpecific: "foo/{id:int}",
+			lessSpecific: "foo/{name}",
+		},
+		"Param over multi-level wildcard": {
+			moreSpecific: "foo/{id}",
+			lessSpecific: "foo/*",
+		},
+		"Exact parent level over multi-level wildcard": {
+			moreSpecific: "foo",
+			lessSpecific: "foo/*",
+		},
+		"Leftmost level decides": {
+			moreSpecific: "foo/{b}/baz",
+			lessSpecific: "{a}/bar/baz",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			more, _ := NewTopic(test.moreSpecific)
+			less, _ := NewTopic(test.lessSpecific)
+
+			if got := more.compareSpecificity(less); got >= 0 {
+				t.Fatalf("Test failed! Expected %s to be more specific than %s, got: %d", test.moreSpecific, test.lessSpecific, got)
+			}
+
+			if got := less.compareSpecificity(more); got <= 0 {
+				t.Fatalf("Test failed! Expected %s to be less specific than %s, got: %d", test.lessSpecific, test.moreSpecific, got)
+			}
+		})
+	}
+}
+
+func Test_Router_StructuralDuplicates(t *testing.T) {
+	type testCase struct {
+		existing string
+		added    string
+		policy   OverlapPolicy
+		expected error
+	}
+
+	tests := map[string]testCase{
+		"Same topic": {
+			existing: "foo/{id}",
+			added:    "foo/{id}",
+			expected: ErrDuplicateSubscription,
+		},
+		"Different param names": {
+			existing: "foo/{id}",
+			added:    "foo/{foo_id}",
+			expected: ErrDuplicateSubscription,
+		},
+		"Same typed params": {
+			existing: "foo/{id:int}",
+			added:    "foo/{foo_id:int}",
+			expected: ErrDuplicateSubscription,
+		},
+		"Multi-level wildcards": {
+			existing: "foo/*",
+			added:    "foo/{path*}",
+			expected: ErrDuplicateSubscription,
+		},
+		"Overlap - allowed": {
+			existing: "foo/{id}",
+			added:    "foo/*",
+		},
+		"Overlap - rejected": {
+			existing: "foo/{id}",
+			added:    "foo/*",
+			policy:   OverlapReject,
+			expected: ErrOverlappingSubscription,
+		},
+		"No overlap - reject policy": {
+			existing: "foo/{id}",
+			added:    "bar/*",
+			policy:   OverlapReject,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := newTestRouter(newFakeTransport(), WithOverlapPolicy(test.policy))
+
+			handler := func(_ Publisher, _ RoutedMessage) error { return nil }
+			if err := r.AddSubscription(test.existing, handler); err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			if err := r.AddSubscription(test.added, handler); !errors.Is(err, test.expected) {
+				t.Fatalf("Test failed! Expected error: %v, got: %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func Test_Router_OverlapPolicy(t *testing.T) {
+	type testCase struct {
+		policy   OverlapPolicy
+		concrete string
+		expected []string
+	}
+
+	tests := map[string]testCase{
+		"Deliver all": {
+			policy:   OverlapDeliverAll,
+			concrete: "foo/1",
+			expected: []string{"foo/*", "foo/{id:int}", "foo/{id}"},
+		},
+		"Most specific - typed param": {
+			policy:   OverlapMostSpecific,
+			concrete: "foo/1",
+			expected: []string{"foo/{id:int}"},
+		},
+		"Most specific - constraint not satisfied": {
+			policy:   OverlapMostSpecific,
+			concrete: "foo/abc",
+			expected: []string{"foo/{id}"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport := newFakeTransport()
+			r := newTestRouter(transport, WithOverlapPolicy(test.policy))
+
+			var mu sync.Mutex
+			var got []string
+			for _, rawTopic := range []string{"foo/*", "foo/{id:int}", "foo/{id}"} {
+				_ = r.AddSubscription(rawTopic, func(_ Publisher, _ RoutedMessage) error {
+					mu.Lock()
+					got = append(got, rawTopic)
+					mu.Unlock()
+					return nil
+				})
+			}
+
+			if err := r.Start(); err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			for _, rawTopic := range []string{"foo/*", "foo/{id:int}", "foo/{id}"} {
+				topic, _ := NewTopic(rawTopic)
+				match, _ := topic.Match(test.concrete)
+				transport.deliverWithParams(rawTopic, test.concrete, match.Params(), Message{})
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			_ = r.Shutdown(context.Background())
+
+			slices.Sort(got)
+			if !slices.Equal(got, test.expected) {
+				t.Fatalf("Test failed! Expected: %v, got: %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package beacon
+
+// Payloads published to the presence topic, see WithPresence.
+const (
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+)
+
+// WithPresence publishes a retained PresenceOnline message to rawTopic once the router has started, and
+// a retained PresenceOffline message when it shuts down. Configure the transport's last will with the
+// same topic and PresenceOffline as payload to also cover services that stop unexpectedly. Presence is
+// published as a raw payload, bypassing the transport's envelope, so that it matches the last will.
+func WithPresence(rawTopic string) func(*Router) {
+	return func(r *Router) {
+		r.presenceTopic = rawTopic
+	}
+}
+
+func (r *Router) publishPresence(status string) {
+	if r.presenceTopic == "" {
+		return
+	}
+
+	err := r.Publish(r.presenceTopic, Message{ContentType: "text/plain", Payload: []byte(status)}, WithRetain(), WithRawPayload())
+	if err != nil {
+		r.logger.Error("Error publishing presence.", "topic", r.presenceTopic, "status", status, "error", err)
+	}
+}
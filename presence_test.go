@@ -0,0 +1,34 @@
+package beacon
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func Test_Router_Presence(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport, WithPresence("services/foo/status"))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	expectedTopics := []string{"services/foo/status", "services/foo/status"}
+	if !slices.Equal(transport.publishedTopics, expectedTopics) {
+		t.Fatalf("Test failed! Expected topics: %v, got: %v", expectedTopics, transport.publishedTopics)
+	}
+
+	for i, status := range []string{PresenceOnline, PresenceOffline} {
+		if string(transport.published[i].Payload) != status {
+			t.Fatalf("Test failed! Expected status: %s, got: %s", status, transport.published[i].Payload)
+		}
+		if !transport.publishOptions[i].Retain {
+			t.Fatalf("Test failed! Expected %s status to be retained.", status)
+		}
+	}
+}
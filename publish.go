@@ -0,0 +1,86 @@
+package beacon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnsupportedPublishOption = errors.New("publish option is not supported by the transport")
+	ErrInvalidPublishOption     = errors.New("publish option is invalid")
+)
+
+// PublishOptions are the options of a single publish, resolved from a list of PublishOption.
+// Transports must return ErrUnsupportedPublishOption for options they can not honour.
+type PublishOptions struct {
+	// Delivery guarantee of the message, from 0 (at most once) to 2 (exactly once). Nil to use the
+	// transport's default.
+	QoS *byte
+
+	// Whether the transport keeps the message and delivers it to future subscribers of the topic.
+	Retain bool
+
+	// Time after which the message is discarded if it was not delivered yet. Zero if it never expires.
+	Expiry time.Duration
+
+	// Priority of the message over others on the same transport, higher first. Nil if not set.
+	Priority *int
+
+	// Whether the payload is sent as is, even if the transport wraps messages in an envelope.
+	Raw bool
+}
+
+type PublishOption func(*PublishOptions)
+
+// NewPublishOptions resolves a list of PublishOption, to be used by transports.
+func NewPublishOptions(options ...PublishOption) (PublishOptions, error) {
+	var opts PublishOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	if opts.QoS != nil && *opts.QoS > 2 {
+		return PublishOptions{}, fmt.Errorf("%w: QoS must be 0, 1 or 2, got %d", ErrInvalidPublishOption, *opts.QoS)
+	}
+
+	if opts.Expiry < 0 {
+		return PublishOptions{}, fmt.Errorf("%w: expiry can not be negative, got %s", ErrInvalidPublishOption, opts.Expiry)
+	}
+
+	return opts, nil
+}
+
+func WithQoS(qos byte) func(*PublishOptions) {
+	return func(o *PublishOptions) {
+		o.QoS = &qos
+	}
+}
+
+// WithRetain asks the transport to keep the message as the last one of its topic, delivering it to
+// subscribers that subscribe afterwards.
+func WithRetain() func(*PublishOptions) {
+	return func(o *PublishOptions) {
+		o.Retain = true
+	}
+}
+
+// WithRawPayload sends the payload as is on transports configured to wrap messages in an envelope, e.g.
+// for consumers that do not understand it. Headers and the remaining message metadata are then dropped.
+func WithRawPayload() func(*PublishOptions) {
+	return func(o *PublishOptions) {
+		o.Raw = true
+	}
+}
+
+func WithExpiry(expiry time.Duration) func(*PublishOptions) {
+	return func(o *PublishOptions) {
+		o.Expiry = expiry
+	}
+}
+
+func WithPriority(priority int) func(*PublishOptions) {
+	return func(o *PublishOptions) {
+		o.Priority = &priority
+	}
+}
@@ -0,0 +1,56 @@
+package beacon
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_NewPublishOptions(t *testing.T) {
+	opts, err := NewPublishOptions(WithQoS(1), WithRetain(), WithExpiry(time.Minute), WithPriority(5))
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if opts.QoS == nil || *opts.QoS != 1 {
+		t.Fatalf("Test failed! Expected QoS: %d, got: %v", 1, opts.QoS)
+	}
+	if !opts.Retain {
+		t.Fatal("Test failed! Expected message to be retained.")
+	}
+	if opts.Expiry != time.Minute {
+		t.Fatalf("Test failed! Expected expiry: %s, got: %s", time.Minute, opts.Expiry)
+	}
+	if opts.Priority == nil || *opts.Priority != 5 {
+		t.Fatalf("Test failed! Expected priority: %d, got: %v", 5, opts.Priority)
+	}
+}
+
+func Test_NewPublishOptions_Invalid(t *testing.T) {
+	tests := map[string][]PublishOption{
+		"QoS out of range": {WithQoS(3)},
+		"Negative expiry":  {WithExpiry(-time.Second)},
+	}
+
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewPublishOptions(options...); !errors.Is(err, ErrInvalidPublishOption) {
+				t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidPublishOption, err)
+			}
+		})
+	}
+}
+
+func Test_Router_PublishOptions(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	if err := r.Publish("foo", Message{}, WithQoS(2), WithRetain()); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	opts := transport.publishOptions[0]
+	if opts.QoS == nil || *opts.QoS != 2 || !opts.Retain {
+		t.Fatalf("Test failed! Expected QoS 2 and retained, got: %+v", opts)
+	}
+}
@@ -1,80 +1,75 @@
 package publishers
 
 import (
-	"regexp"
-	"strings"
-
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/pmoura-dev/beacon"
+	"github.com/pmoura-dev/beacon/internal/mqttconn"
+	"github.com/pmoura-dev/beacon/mqttclient"
 )
 
 type MQTTPublisher struct {
-	client               mqtt.Client
-	qos                  byte
-	disconnectionTimeout uint // milliseconds
+	conn   *mqttconn.Conn
+	config mqttconn.Config
 }
 
 type MQTTPublisherOption func(*MQTTPublisher)
 
 func NewMQTTPublisher(url string, options ...MQTTPublisherOption) *MQTTPublisher {
-	opts := mqtt.NewClientOptions().AddBroker(url)
-	opts.SetCleanSession(true)
-
 	publisher := &MQTTPublisher{
-		client:               mqtt.NewClient(opts),
-		qos:                  0,
-		disconnectionTimeout: 250,
+		config: mqttconn.Config{
+			QOS:                  0,
+			DisconnectionTimeout: 250,
+		},
 	}
 
 	for _, opt := range options {
 		opt(publisher)
 	}
 
+	publisher.conn = mqttconn.New(url, publisher.config)
+
 	return publisher
 }
 
+// WithClientOptions configures the underlying MQTT client, e.g. its TLS configuration or credentials.
+func WithClientOptions(options ...mqttclient.Option) func(*MQTTPublisher) {
+	return func(b *MQTTPublisher) {
+		b.config.ClientOptions = append(b.config.ClientOptions, options...)
+	}
+}
+
 func WithQOS(qos byte) func(*MQTTPublisher) {
 	return func(b *MQTTPublisher) {
-		b.qos = qos
+		b.config.QOS = qos
 	}
 }
 
 func WithDisconnectionTimeout(timeout uint) func(*MQTTPublisher) {
 	return func(b *MQTTPublisher) {
-		b.disconnectionTimeout = timeout
+		b.config.DisconnectionTimeout = timeout
 	}
 }
 
-func (b *MQTTPublisher) Connect() error {
-	if token := b.client.Connect(); token.Wait() && token.Error() != nil {
-		return token.Error()
+// WithEnvelope wraps every message with beacon.MarshalEnvelope so that headers, such as those
+// used by request/reply, survive MQTT 3.1.1. Subscribers must enable the envelope as well. Without it,
+// requests fail to publish with beacon.ErrUnsupportedPublishOption.
+func WithEnvelope() func(*MQTTPublisher) {
+	return func(b *MQTTPublisher) {
+		b.config.Envelope = true
 	}
+}
 
-	return nil
+func (b *MQTTPublisher) Connect() error {
+	return b.conn.Connect()
 }
 
 func (b *MQTTPublisher) Disconnect() error {
-	b.client.Disconnect(b.disconnectionTimeout)
-	return nil
+	return b.conn.Disconnect()
 }
 
-func (b *MQTTPublisher) Publish(topic *beacon.Topic, message beacon.Message) error {
-	mqttTopic := toMQTTTopic(topic.Raw())
-
-	token := b.client.Publish(mqttTopic, b.qos, false, message.Payload)
-	if token.Wait() && token.Error() != nil {
-		return token.Error()
-	}
-
-	return nil
+func (b *MQTTPublisher) OnConnectionStateChange(handler beacon.ConnectionStateHandler) {
+	b.conn.OnConnectionStateChange(handler)
 }
 
-func toMQTTTopic(topic string) string {
-	// Replace named wildcards {param} with "+"
-	mqttTopic := regexp.MustCompile(`\{[^}]+\}`).ReplaceAllString(topic, "+")
-
-	// Replace * with "#"
-	mqttTopic = strings.ReplaceAll(mqttTopic, "*", "#")
-
-	return mqttTopic
+func (b *MQTTPublisher) Publish(topic *beacon.Topic, message beacon.Message, options ...beacon.PublishOption) error {
+	return b.conn.Publish(topic, message, options...)
 }
@@ -1,64 +1,49 @@
 package publishers
 
 import (
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/pmoura-dev/beacon"
 )
 
-func Test_toMQTTTopic(t *testing.T) {
-	type testCase struct {
-		topic    string
-		expected string
+func Test_Publish_WildcardTopic(t *testing.T) {
+	tests := map[string]string{
+		"Single level wildcard":       "foo/{foo_id}/bar",
+		"Typed single level wildcard": "foo/{foo_id:int}",
+		"Multi level wildcard":        "foo/*",
+		"Named multi level wildcard":  "files/{path*}",
+		"MQTT single level wildcard":  "foo/+/bar",
+		"MQTT multi level wildcard":   "foo/#",
 	}
 
-	tests := map[string]testCase{
-		"Simple - one segment": {
-			topic:    "foo",
-			expected: "foo",
-		},
-		"Simple - multiple segments": {
-			topic:    "foo/bar/baz",
-			expected: "foo/bar/baz",
-		},
-		"Single level wildcard - single": {
-			topic:    "{foo_id}",
-			expected: "+",
-		},
-		"Single level wildcard - beginning": {
-			topic:    "{foo_id}/bar",
-			expected: "+/bar",
-		},
-		"Single level wildcard - middle": {
-			topic:    "foo/{foo_id}/bar",
-			expected: "foo/+/bar",
-		},
-		"Single level wildcard - end": {
-			topic:    "foo/{foo_id}",
-			expected: "foo/+",
-		},
-		"Single level wildcard - multiple": {
-			topic:    "foo/{foo_id}/bar/{bar_id}",
-			expected: "foo/+/bar/+",
-		},
-		"Multi level wildcard - root": {
-			topic:    "*",
-			expected: "#",
-		},
-		"Multi level wildcard - end": {
-			topic:    "foo/*",
-			expected: "foo/#",
-		},
-		"Multi level wildcard - with single level wildcard before": {
-			topic:    "foo/{foo_id}/*",
-			expected: "foo/+/#",
-		},
-	}
+	publisher := NewMQTTPublisher("mqtt://localhost:1883")
 
-	for name, test := range tests {
+	for name, rawTopic := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := toMQTTTopic(test.topic)
+			topic, _ := beacon.NewTopic(rawTopic)
 
-			if test.expected != got {
-				t.Fatalf("Test failed! Expected: %s, got: %s", test.expected, got)
+			if err := publisher.Publish(topic, beacon.Message{}); err != beacon.ErrWildcardTopic {
+				t.Fatalf("Test failed! Expected error: %v, got: %v", beacon.ErrWildcardTopic, err)
+			}
+		})
+	}
+}
+
+func Test_Publish_UnsupportedOptions(t *testing.T) {
+	tests := map[string]beacon.PublishOption{
+		"Expiry":   beacon.WithExpiry(time.Minute),
+		"Priority": beacon.WithPriority(1),
+	}
+
+	publisher := NewMQTTPublisher("mqtt://localhost:1883")
+	topic, _ := beacon.NewTopic("foo/bar")
+
+	for name, option := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := publisher.Publish(topic, beacon.Message{}, option); !errors.Is(err, beacon.ErrUnsupportedPublishOption) {
+				t.Fatalf("Test failed! Expected error: %v, got: %v", beacon.ErrUnsupportedPublishOption, err)
 			}
 		})
 	}
@@ -0,0 +1,76 @@
+package beacon
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned in place of a panic raised by a handler.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// invoke runs the handler, converting a panic into a *PanicError so that it follows the same
+// path as a returned error.
+func (r *Router) invoke(ctx context.Context, handler ContextHandlerFunc, message RoutedMessage) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			stack := debug.Stack()
+			r.logger.Error("Recovered from panic in handler.", "topic", message.Topic.FullName(), "panic", v, "stack", string(stack))
+			err = &PanicError{Value: v, Stack: stack}
+		}
+	}()
+
+	return handler(ctx, r.broker, message)
+}
+
+// shard returns the worker queue for the message like subscription.shard. If the ordering key function
+// panics, the message goes to the first queue, as it has no key to keep in order with.
+func (r *Router) shard(sub *subscription, message RoutedMessage, workers int) (index int) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.logger.Error("Recovered from panic in ordering key function.", "topic", message.Topic.FullName(), "panic", v, "stack", string(debug.Stack()))
+			index = 0
+		}
+	}()
+
+	return sub.shard(message, workers)
+}
+
+// retryable reports whether err should be retried like RetryPolicy.retryable. If the policy's Retryable
+// function panics, the error is not retried.
+func (r *Router) retryable(policy RetryPolicy, message RoutedMessage, err error) (retry bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.logger.Error("Recovered from panic in retryable function.", "topic", message.Topic.FullName(), "panic", v, "stack", string(debug.Stack()))
+			retry = false
+		}
+	}()
+
+	return policy.retryable(err)
+}
+
+// deadLetterTopicName returns the dead-letter topic of the message. If topicFunc panics, ok is false and the
+// message is not dead-lettered.
+func (r *Router) deadLetterTopicName(topicFunc DeadLetterTopicFunc, message RoutedMessage) (topic string, ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.logger.Error("Recovered from panic in dead-letter topic function.", "topic", message.Topic.FullName(), "panic", v, "stack", string(debug.Stack()))
+			topic, ok = "", false
+		}
+	}()
+
+	return topicFunc(message.Topic), true
+}
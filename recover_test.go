@@ -0,0 +1,153 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Router_PanicRecovery(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	var handled atomic.Int32
+	_ = r.AddSubscription("foo", func(_ Publisher, message RoutedMessage) error {
+		handled.Add(1)
+		if string(message.Payload) == "panic" {
+			panic("boom")
+		}
+		return nil
+	})
+
+	errs := make(chan error, 2)
+	_ = r.UseContextMiddleware(func(next ContextHandlerFunc) ContextHandlerFunc {
+		return func(ctx context.Context, publisher Publisher, message RoutedMessage) error {
+			err := next(ctx, publisher, message)
+			if err != nil {
+				errs <- err
+			}
+			return err
+		}
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	transport.deliver("foo", "foo", Message{Payload: []byte("panic")})
+	transport.deliver("foo", "foo", Message{Payload: []byte("ok")})
+
+	time.Sleep(50 * time.Millisecond)
+	_ = r.Shutdown(context.Background())
+
+	if got := handled.Load(); got != 2 {
+		t.Fatalf("Test failed! Expected handled messages: %d, got: %d", 2, got)
+	}
+
+	select {
+	case err := <-errs:
+		t.Fatalf("Test failed! Panic should not be seen as an error by middleware, got: %v", err)
+	default:
+	}
+}
+
+func Test_Router_PanicDeadLetter(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport, WithDeadLetter(DeadLetterPrefix("dlq")))
+
+	errPanic := errors.New("boom")
+	_ = r.AddSubscription("foo", func(_ Publisher, _ RoutedMessage) error {
+		panic(errPanic)
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	transport.deliver("foo", "foo", Message{})
+
+	time.Sleep(50 * time.Millisecond)
+	_ = r.Shutdown(context.Background())
+
+	if len(transport.published) != 1 {
+		t.Fatalf("Test failed! Expected one dead-letter message, got: %d", len(transport.published))
+	}
+
+	if got := transport.published[0].GetHeader(HeaderDeadLetterError); got != "handler panicked: boom" {
+		t.Fatalf("Test failed! Expected error header: %s, got: %s", "handler panicked: boom", got)
+	}
+}
+
+func Test_Router_OrderingKeyPanicRecovery(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	var handled atomic.Int32
+	_ = r.AddSubscription("foo", func(_ Publisher, _ RoutedMessage) error {
+		handled.Add(1)
+		return nil
+	}, WithOrderingKey(func(message RoutedMessage) string {
+		if string(message.Payload) == "panic" {
+			panic("boom")
+		}
+		return string(message.Payload)
+	}), WithMaxConcurrency(4))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	transport.deliver("foo", "foo", Message{Payload: []byte("panic")})
+	transport.deliver("foo", "foo", Message{Payload: []byte("ok")})
+
+	time.Sleep(50 * time.Millisecond)
+	_ = r.Shutdown(context.Background())
+
+	if got := handled.Load(); got != 2 {
+		t.Fatalf("Test failed! Expected handled messages: %d, got: %d", 2, got)
+	}
+}
+
+func Test_Router_CallbackPanicRecovery(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport, WithDeadLetter(func(*TopicMatch) string {
+		panic("boom")
+	}))
+
+	var handled atomic.Int32
+	failing := func(_ Publisher, _ RoutedMessage) error {
+		handled.Add(1)
+		return errors.New("failure")
+	}
+
+	_ = r.AddSubscription("foo", failing)
+	_ = r.AddSubscription("bar", failing, WithSubscriptionRetryPolicy(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Retryable: func(error) bool {
+			panic("boom")
+		},
+	}))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	transport.deliver("foo", "foo", Message{})
+	transport.deliver("bar", "bar", Message{})
+	transport.deliver("foo", "foo", Message{})
+
+	time.Sleep(50 * time.Millisecond)
+	_ = r.Shutdown(context.Background())
+
+	// A panicking Retryable function does not retry the message.
+	if got := handled.Load(); got != 3 {
+		t.Fatalf("Test failed! Expected handled messages: %d, got: %d", 3, got)
+	}
+
+	if len(transport.published) != 0 {
+		t.Fatalf("Test failed! Expected no published messages, got: %v", transport.publishedTopics)
+	}
+}
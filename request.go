@@ -0,0 +1,113 @@
+package beacon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// Headers used to correlate requests and replies.
+const (
+	HeaderReplyTo       = "reply-to"
+	HeaderCorrelationID = "correlation-id"
+)
+
+const DefaultReplyPrefix = "_beacon/replies"
+
+var (
+	ErrRequestTimeout = errors.New("request timed out waiting for a reply")
+	ErrNoReplyTo      = errors.New("message does not have a reply topic")
+)
+
+// Request publishes message to topic and waits for a reply. The message is sent with a reply topic and a
+// correlation ID, which the receiver uses to answer with Reply. If ctx expires before a reply arrives,
+// an error wrapping ErrRequestTimeout is returned.
+func (b *Broker) Request(ctx context.Context, topic *Topic, message Message) (RoutedMessage, error) {
+	correlationID, err := newCorrelationID()
+	if err != nil {
+		return RoutedMessage{}, err
+	}
+
+	replyTopic, err := NewTopic(b.replyPrefix + "/" + correlationID)
+	if err != nil {
+		return RoutedMessage{}, err
+	}
+
+	replies, err := b.Subscribe(replyTopic)
+	if err != nil {
+		return RoutedMessage{}, err
+	}
+	defer func() {
+		_ = b.Unsubscribe(replyTopic)
+	}()
+
+	request := message
+	request.Headers = maps.Clone(message.Headers)
+	request.SetHeader(HeaderReplyTo, replyTopic.Raw())
+	request.SetHeader(HeaderCorrelationID, correlationID)
+
+	if err := b.Publish(topic, request); err != nil {
+		return RoutedMessage{}, err
+	}
+
+	for {
+		select {
+		case reply, ok := <-replies:
+			if !ok {
+				return RoutedMessage{}, ErrNoSubscriber
+			}
+
+			// Replies without a correlation ID are accepted, since the reply topic is unique to this request.
+			if id := reply.GetHeader(HeaderCorrelationID); id != "" && id != correlationID {
+				continue
+			}
+
+			return reply, nil
+		case <-ctx.Done():
+			return RoutedMessage{}, fmt.Errorf("%w: %w", ErrRequestTimeout, ctx.Err())
+		}
+	}
+}
+
+// Request publishes a message to rawTopic and waits for a reply. See Broker.Request.
+func (r *Router) Request(ctx context.Context, rawTopic string, message Message) (RoutedMessage, error) {
+	topic, err := NewTopic(rawTopic)
+	if err != nil {
+		return RoutedMessage{}, err
+	}
+
+	return r.broker.Request(ctx, topic, message)
+}
+
+// Reply publishes message to the reply topic of request, carrying over its correlation ID.
+func Reply(publisher Publisher, request RoutedMessage, message Message) error {
+	replyTo := request.GetHeader(HeaderReplyTo)
+	if replyTo == "" {
+		return ErrNoReplyTo
+	}
+
+	topic, err := NewTopic(replyTo)
+	if err != nil {
+		return err
+	}
+
+	reply := message
+	reply.Headers = maps.Clone(message.Headers)
+	if correlationID := request.GetHeader(HeaderCorrelationID); correlationID != "" {
+		reply.SetHeader(HeaderCorrelationID, correlationID)
+	}
+
+	return publisher.Publish(topic, reply)
+}
+
+func newCorrelationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,94 @@
+package beacon
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+type RetryPolicy struct {
+	// Total number of times a message is handled, including the first attempt. Values lower than 2 disable retries.
+	MaxAttempts int
+
+	// Backoff before the first retry. Every following retry multiplies it by Multiplier, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Fraction of the backoff, between 0 and 1, that is randomized to spread retries over time.
+	Jitter float64
+
+	// Reports whether an error should be retried. When nil, every error is retried except
+	// decode errors and errors wrapped with Permanent.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy retries a message up to 3 times with exponential backoff starting at 100ms.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+func noRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// backoff returns how long to wait after the given failed attempt, starting at 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		backoff -= backoff * min(p.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(backoff)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	var permanentErr *permanentError
+	if errors.As(err, &permanentErr) {
+		return false
+	}
+
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	var decodeErr *DecodeError
+	return !errors.As(err, &decodeErr)
+}
+
+type permanentError struct {
+	err error
+}
+
+// Permanent wraps err so that the message is never retried, regardless of the retry policy.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
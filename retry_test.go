@@ -0,0 +1,181 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_RetryPolicy_backoff(t *testing.T) {
+	type testCase struct {
+		attempt  int
+		expected time.Duration
+	}
+
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	tests := map[string]testCase{
+		"First retry": {
+			attempt:  1,
+			expected: 100 * time.Millisecond,
+		},
+		"Exponential growth": {
+			attempt:  3,
+			expected: 400 * time.Millisecond,
+		},
+		"Capped at max backoff": {
+			attempt:  10,
+			expected: time.Second,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := policy.backoff(test.attempt)
+
+			if got != test.expected {
+				t.Fatalf("Test failed! Expected: %v, got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func Test_RetryPolicy_retryable(t *testing.T) {
+	type testCase struct {
+		policy   RetryPolicy
+		err      error
+		expected bool
+	}
+
+	errTransient := errors.New("transient")
+
+	tests := map[string]testCase{
+		"Default - plain error": {
+			err:      errTransient,
+			expected: true,
+		},
+		"Default - decode error": {
+			err:      &DecodeError{Err: errTransient},
+			expected: false,
+		},
+		"Default - permanent error": {
+			err:      Permanent(errTransient),
+			expected: false,
+		},
+		"Custom - classification": {
+			policy:   RetryPolicy{Retryable: func(err error) bool { return !errors.Is(err, errTransient) }},
+			err:      errTransient,
+			expected: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := test.policy.retryable(test.err)
+
+			if got != test.expected {
+				t.Fatalf("Test failed! Expected: %v, got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func Test_Router_Retry(t *testing.T) {
+	type testCase struct {
+		routerPolicy     RetryPolicy
+		options          []SubscriptionOption
+		failures         int32
+		expectedAttempts int32
+	}
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	tests := map[string]testCase{
+		"No retries by default": {
+			routerPolicy:     noRetryPolicy(),
+			failures:         2,
+			expectedAttempts: 1,
+		},
+		"Router policy - succeeds after retries": {
+			routerPolicy:     policy,
+			failures:         2,
+			expectedAttempts: 3,
+		},
+		"Router policy - exhausts attempts": {
+			routerPolicy:     policy,
+			failures:         5,
+			expectedAttempts: 3,
+		},
+		"Subscription policy overrides router policy": {
+			routerPolicy:     policy,
+			options:          []SubscriptionOption{WithSubscriptionRetryPolicy(RetryPolicy{MaxAttempts: 5})},
+			failures:         5,
+			expectedAttempts: 5,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport := newFakeTransport()
+			r := newTestRouter(transport, WithRetryPolicy(test.routerPolicy))
+
+			var attempts atomic.Int32
+			_ = r.AddSubscription("foo", func(_ Publisher, _ RoutedMessage) error {
+				if attempts.Add(1) <= test.failures {
+					return errors.New("failure")
+				}
+				return nil
+			}, test.options...)
+
+			if err := r.Start(); err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			transport.deliver("foo", "foo", Message{})
+			time.Sleep(50 * time.Millisecond)
+			_ = r.Shutdown(context.Background())
+
+			if got := attempts.Load(); got != test.expectedAttempts {
+				t.Fatalf("Test failed! Expected attempts: %d, got: %d", test.expectedAttempts, got)
+			}
+		})
+	}
+}
+
+func Test_Router_RetryAbandonedOnShutdownTimeout(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport, WithRetryPolicy(RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour}))
+
+	var attempts atomic.Int32
+	_ = r.AddSubscription("foo", func(_ Publisher, _ RoutedMessage) error {
+		attempts.Add(1)
+		return errors.New("failure")
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	transport.deliver("foo", "foo", Message{})
+	time.Sleep(20 * time.Millisecond)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_ = r.Shutdown(shutdownCtx)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Test failed! Shutdown took too long: %v", elapsed)
+	}
+
+	if got := attempts.Load(); got != 1 {
+		t.Fatalf("Test failed! Expected attempts: %d, got: %d", 1, got)
+	}
+}
@@ -3,27 +3,58 @@ package beacon
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
 	"sync"
+	"time"
 )
 
 var (
 	ErrCannotAddSubscription   = errors.New("subscription can not be added")
 	ErrCannotAddMiddleware     = errors.New("middleware can not be added")
 	ErrDuplicateSubscription   = errors.New("subscription already exists")
+	ErrSubscriptionNotFound    = errors.New("subscription does not exist")
+	ErrOverlappingSubscription = errors.New("subscription overlaps with an existing one")
 	ErrShutdownTimeoutExceeded = errors.New("shutdown timeout exceeded")
 )
 
 type Router struct {
 	broker *Broker
 	logger *slog.Logger
+	codec  Codec
 
-	middlewareChain Middleware
+	retryPolicy     RetryPolicy
+	deadLetterTopic DeadLetterTopicFunc
 
-	subscriptions map[*Topic]HandlerFunc
+	middlewareChain ContextMiddleware
+
+	subscriptions map[*Topic]*subscription
+
+	// Index of the subscriptions, used to find the most specific subscription for a message. It has its
+	// own lock so that handling messages never waits for subscriptions being added or removed.
+	subscriptionTrie *TopicTrie[*subscription]
+	trieMu           sync.RWMutex
+	overlapPolicy    OverlapPolicy
+
+	connectionStateHandler ConnectionStateHandler
+
+	// Topic on which the router announces whether it is online, if any.
+	presenceTopic string
+
+	// Protects subscriptions and isRunning, which can change while the router is running.
+	mu sync.RWMutex
+
+	// Serializes adding and removing subscriptions. Unlike mu, it is held while the broker subscribes
+	// or unsubscribes, which may wait for messages received in the meantime to be handled.
+	changeMu sync.Mutex
 
 	wg sync.WaitGroup
 
+	// Context passed to handlers. It is cancelled when the shutdown context expires.
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	// Channel that indicates if the router is in shutdownChan mode.
 	shutdownChan chan struct{}
 
@@ -32,11 +63,19 @@ type Router struct {
 }
 
 func NewRouter(broker *Broker, options ...OptionFunc) *Router {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	r := &Router{
-		broker:          broker,
-		logger:          slog.Default(),
-		subscriptions:   make(map[*Topic]HandlerFunc),
-		middlewareChain: identityMiddleware,
+		broker:           broker,
+		logger:           slog.Default(),
+		codec:            JSONCodec{},
+		retryPolicy:      noRetryPolicy(),
+		subscriptions:    make(map[*Topic]*subscription),
+		subscriptionTrie: NewTopicTrie[*subscription](),
+		middlewareChain:  identityMiddleware,
+
+		ctx:    ctx,
+		cancel: cancel,
 
 		shutdownChan: make(chan struct{}),
 	}
@@ -56,9 +95,48 @@ func WithLogger(logger *slog.Logger) func(*Router) {
 	}
 }
 
+// WithCodec sets the codec used to decode the payloads of typed subscriptions.
+func WithCodec(codec Codec) func(*Router) {
+	return func(r *Router) {
+		r.codec = codec
+	}
+}
+
+// WithRetryPolicy sets the retry policy used for failing handlers. By default, messages are not retried.
+func WithRetryPolicy(policy RetryPolicy) func(*Router) {
+	return func(r *Router) {
+		r.retryPolicy = policy
+	}
+}
+
+// WithDeadLetter republishes messages whose handler permanently failed to the topic returned by topicFunc.
+func WithDeadLetter(topicFunc DeadLetterTopicFunc) func(*Router) {
+	return func(r *Router) {
+		r.deadLetterTopic = topicFunc
+	}
+}
+
+// WithOverlapPolicy sets how messages are dispatched when the topics of several subscriptions match them.
+// By default, messages are delivered to every matching subscription.
+func WithOverlapPolicy(policy OverlapPolicy) func(*Router) {
+	return func(r *Router) {
+		r.overlapPolicy = policy
+	}
+}
+
+// WithConnectionStateHandler sets a handler called whenever the state of the broker connection changes,
+// in addition to the router logging it.
+func WithConnectionStateHandler(handler ConnectionStateHandler) func(*Router) {
+	return func(r *Router) {
+		r.connectionStateHandler = handler
+	}
+}
+
 func (r *Router) Start() error {
 	r.logger.Info("Starting Beacon...")
 
+	r.broker.OnConnectionStateChange(r.onConnectionStateChange)
+
 	err := r.broker.Connect()
 	if err != nil {
 		return err
@@ -67,44 +145,200 @@ func (r *Router) Start() error {
 	r.logger.Info("Connected to broker.")
 
 	r.startListening()
+	r.publishPresence(PresenceOnline)
 
 	r.logger.Info("Beacon started.")
 	return nil
 }
 
+func (r *Router) onConnectionStateChange(event ConnectionEvent) {
+	switch event.State {
+	case ConnectionLost:
+		r.logger.Warn("Lost connection to broker.", "error", event.Err)
+	case ConnectionReconnecting:
+		r.logger.Info("Reconnecting to broker...")
+	case ConnectionConnected:
+		if event.Err != nil {
+			r.logger.Error("Connected to broker, but not every subscription was restored.", "error", event.Err)
+			break
+		}
+		r.logger.Debug("Broker connection established.")
+	}
+
+	if r.connectionStateHandler != nil {
+		r.connectionStateHandler(event)
+	}
+}
+
 func (r *Router) startListening() {
-	for topic, handler := range r.subscriptions {
-		messageChan, err := r.broker.Subscribe(topic)
-		if err != nil {
+	r.changeMu.Lock()
+	defer r.changeMu.Unlock()
+
+	r.mu.RLock()
+	subscriptions := maps.Clone(r.subscriptions)
+	r.mu.RUnlock()
+
+	for topic, sub := range subscriptions {
+		if err := r.listen(sub); err != nil {
 			r.logger.Error("Error adding subscription", "topic", topic, "error", err)
 			continue
 		}
 
-		r.wg.Add(1)
-		go func() {
-			defer r.wg.Done()
-			for {
+		r.logger.Info("Added subscription.", "topic", topic)
+	}
+
+	r.mu.Lock()
+	r.isRunning = true
+	r.mu.Unlock()
+}
+
+func (r *Router) listen(sub *subscription) error {
+	messageChan, err := r.broker.Subscribe(sub.topic, sub.subscribeOptions()...)
+	if err != nil {
+		return err
+	}
+
+	r.dispatch(sub, messageChan)
+	return nil
+}
+
+// dispatch reads messages from messageChan into the subscription's buffers and starts the workers
+// that handle them. Messages already buffered when the router shuts down are still handled.
+func (r *Router) dispatch(sub *subscription, messageChan <-chan RoutedMessage) {
+	workers := sub.workers()
+
+	// Ordered and parallel subscriptions share a single queue between workers, while keyed
+	// subscriptions give each worker its own queue so that messages with the same key keep their order.
+	queues := make([]chan RoutedMessage, 1)
+	if sub.dispatchMode == DispatchKeyed {
+		queues = make([]chan RoutedMessage, workers)
+	}
+
+	for i := range queues {
+		queues[i] = make(chan RoutedMessage, sub.bufferSize)
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() {
+			for _, queue := range queues {
+				close(queue)
+			}
+		}()
+
+		for {
+			select {
+			case <-r.shutdownChan:
+				r.logger.Info("Router is in shutdown phase. Stopped listening for messages.", "topic", sub.topic)
+				return
+			case <-sub.stop:
+				r.logger.Info("Subscription removed. Stopped listening for messages.", "topic", sub.topic)
+				return
+			case message, ok := <-messageChan:
+				if !ok {
+					r.logger.Info("Subscription channel closed. Stopped listening for messages.", "topic", sub.topic)
+					return
+				}
+
 				select {
+				case queues[r.shard(sub, message, len(queues))] <- message:
 				case <-r.shutdownChan:
-					r.logger.Info("Router is in shutdown phase. Stopped listening for messages.", "topic", topic)
+					r.logger.Info("Router is in shutdown phase. Stopped listening for messages.", "topic", sub.topic)
+					return
+				case <-sub.stop:
+					r.logger.Info("Subscription removed. Stopped listening for messages.", "topic", sub.topic)
 					return
-				case message := <-messageChan:
-					err := r.middlewareChain(handler)(r.broker, message)
-					if err != nil {
-						r.logger.Error("Error processing message.", "error", err)
-					}
 				}
 			}
+		}
+	}()
+
+	for i := range workers {
+		queue := queues[i%len(queues)]
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			for message := range queue {
+				r.handle(sub, message)
+			}
 		}()
+	}
+}
 
-		r.logger.Info("Added subscription.", "topic", topic)
+func (r *Router) handle(sub *subscription, message RoutedMessage) {
+	if err := sub.topic.Validate(message.Topic); err != nil {
+		r.logger.Warn("Rejected message.", "topic", message.Topic.FullName(), "error", err)
+		return
+	}
+
+	if r.overlapPolicy == OverlapMostSpecific && !r.isMostSpecific(sub, message.Topic.FullName()) {
+		r.logger.Debug("Skipped message handled by a more specific subscription.", "topic", message.Topic.FullName(), "subscription", sub.topic.Raw())
+		return
+	}
+
+	attempts, err := r.process(sub, message)
+	if err == nil {
+		return
+	}
+
+	r.logger.Error("Error processing message.", "topic", message.Topic.FullName(), "attempts", attempts, "error", err)
+
+	deadLetterTopic := r.deadLetterTopic
+	if sub.deadLetterTopic != nil {
+		deadLetterTopic = sub.deadLetterTopic
+	}
+
+	if deadLetterTopic != nil {
+		r.deadLetter(deadLetterTopic, message, attempts, err)
+	}
+}
+
+// process runs the handler of the subscription, retrying it according to the retry policy.
+// Pending retries are abandoned when the handler context is cancelled during shutdown.
+func (r *Router) process(sub *subscription, message RoutedMessage) (int, error) {
+	policy := r.retryPolicy
+	if sub.retryPolicy != nil {
+		policy = *sub.retryPolicy
+	}
+
+	handler := r.middlewareChain(sub.handler)
+
+	for attempt := 1; ; attempt++ {
+		err := r.invoke(r.ctx, handler, message)
+		if err == nil {
+			return attempt, nil
+		}
+
+		if attempt >= policy.MaxAttempts || !r.retryable(policy, message, err) || r.ctx.Err() != nil {
+			return attempt, err
+		}
+
+		backoff := policy.backoff(attempt)
+		r.logger.Warn("Retrying message.", "topic", message.Topic.FullName(), "attempt", attempt, "backoff", backoff, "error", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-r.ctx.Done():
+			timer.Stop()
+			r.logger.Info("Router is in shutdown phase. Abandoned retry.", "topic", message.Topic.FullName(), "attempt", attempt)
+			return attempt, err
+		}
 	}
 }
 
 func (r *Router) Shutdown(ctx context.Context) error {
 	r.logger.Info("Shutting down Beacon...")
+
+	r.mu.Lock()
+	r.isRunning = false
+	r.mu.Unlock()
+
 	close(r.shutdownChan)
 
+	r.publishPresence(PresenceOffline)
 	_ = r.broker.Disconnect()
 
 	r.logger.Info("Waiting for in-flight messages to be processed.")
@@ -122,32 +356,144 @@ func (r *Router) Shutdown(ctx context.Context) error {
 		r.logger.Info("Forcing shutdown.", "error", ErrShutdownTimeoutExceeded)
 	}
 
+	r.cancel()
+
 	r.logger.Info("Beacon shutdown.")
 	return nil
 }
 
-func (r *Router) AddSubscription(rawTopic string, handler HandlerFunc) error {
-	if r.isRunning {
-		r.logger.Error("Subscription could not be added. Router is already running.", "topic", rawTopic)
-		return ErrCannotAddSubscription
-	}
+func (r *Router) AddSubscription(rawTopic string, handler HandlerFunc, options ...SubscriptionOption) error {
+	return r.AddContextSubscription(rawTopic, FromHandlerFunc(handler), options...)
+}
 
+// AddContextSubscription adds a subscription to the router. If the router is already running,
+// the topic is subscribed to immediately.
+func (r *Router) AddContextSubscription(rawTopic string, handler ContextHandlerFunc, options ...SubscriptionOption) error {
 	topic, err := NewTopic(rawTopic)
 	if err != nil {
 		r.logger.Error("Invalid topic definition.", "topic", rawTopic)
 		return err
 	}
 
-	if _, exists := r.subscriptions[topic]; exists {
-		r.logger.Error("A subscription to this topic already exists", "topic", rawTopic)
-		return ErrDuplicateSubscription
+	r.changeMu.Lock()
+	defer r.changeMu.Unlock()
+
+	if err := r.checkOverlaps(topic); err != nil {
+		return err
+	}
+
+	sub := newSubscription(topic, handler, options...)
+
+	if err := sub.validate(); err != nil {
+		r.logger.Error("Invalid subscription options.", "topic", rawTopic, "error", err)
+		return err
+	}
+
+	if _, err := NewSubscribeOptions(sub.subscribeOptions()...); err != nil {
+		r.logger.Error("Invalid subscription options.", "topic", rawTopic, "error", err)
+		return err
+	}
+
+	r.mu.RLock()
+	isRunning := r.isRunning
+	r.mu.RUnlock()
+
+	if isRunning {
+		if err := r.listen(sub); err != nil {
+			r.logger.Error("Error adding subscription", "topic", topic, "error", err)
+			return err
+		}
+
+		r.logger.Info("Added subscription.", "topic", topic)
+	}
+
+	r.mu.Lock()
+	r.subscriptions[topic] = sub
+	r.mu.Unlock()
+
+	r.trieMu.Lock()
+	r.subscriptionTrie.Insert(topic, sub)
+	r.trieMu.Unlock()
+
+	return nil
+}
+
+// checkOverlaps returns an error if topic duplicates an existing subscription or overlaps with one when
+// using OverlapReject.
+func (r *Router) checkOverlaps(topic *Topic) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for existing := range r.subscriptions {
+		if existing.shape() == topic.shape() {
+			r.logger.Error("A subscription to this topic already exists", "topic", topic.Raw(), "existing", existing.Raw())
+			return ErrDuplicateSubscription
+		}
+
+		if r.overlapPolicy == OverlapReject && existing.Overlaps(topic) {
+			r.logger.Error("Subscription overlaps with an existing one.", "topic", topic.Raw(), "existing", existing.Raw())
+			return fmt.Errorf("%w: %q overlaps with %q", ErrOverlappingSubscription, topic.Raw(), existing.Raw())
+		}
 	}
 
-	r.subscriptions[topic] = handler
 	return nil
 }
 
+// RemoveSubscription removes a subscription from the router. If the router is running, the topic is
+// unsubscribed from and the subscription stops listening for messages. Messages already received are
+// still handled.
+func (r *Router) RemoveSubscription(rawTopic string) error {
+	r.changeMu.Lock()
+	defer r.changeMu.Unlock()
+
+	topic, sub, isRunning := r.findSubscription(rawTopic)
+	if sub == nil {
+		return ErrSubscriptionNotFound
+	}
+
+	if isRunning {
+		if err := r.broker.Unsubscribe(topic); err != nil {
+			r.logger.Error("Error removing subscription", "topic", topic, "error", err)
+			return err
+		}
+
+		close(sub.stop)
+		r.logger.Info("Removed subscription.", "topic", topic)
+	}
+
+	r.mu.Lock()
+	delete(r.subscriptions, topic)
+	r.mu.Unlock()
+
+	r.trieMu.Lock()
+	r.subscriptionTrie.Remove(topic)
+	r.trieMu.Unlock()
+
+	return nil
+}
+
+// findSubscription returns the subscription to rawTopic, if any, and whether the router is running.
+func (r *Router) findSubscription(rawTopic string) (*Topic, *subscription, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for topic, sub := range r.subscriptions {
+		if topic.Raw() == rawTopic {
+			return topic, sub, r.isRunning
+		}
+	}
+
+	return nil, nil, r.isRunning
+}
+
 func (r *Router) UseMiddleware(middleware Middleware) error {
+	return r.UseContextMiddleware(FromMiddleware(middleware))
+}
+
+func (r *Router) UseContextMiddleware(middleware ContextMiddleware) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	if r.isRunning {
 		r.logger.Error("Middleware could not be added. Router is already running.")
 		return ErrCannotAddMiddleware
@@ -155,26 +501,72 @@ func (r *Router) UseMiddleware(middleware Middleware) error {
 
 	prev := r.middlewareChain
 
-	r.middlewareChain = func(next HandlerFunc) HandlerFunc {
+	r.middlewareChain = func(next ContextHandlerFunc) ContextHandlerFunc {
 		return middleware(prev(next))
 	}
 
 	return nil
 }
 
-func (r *Router) Publish(rawTopic string, message Message) error {
+func (r *Router) Publish(rawTopic string, message Message, options ...PublishOption) error {
 	topic, err := NewTopic(rawTopic)
 	if err != nil {
 		return err
 	}
 
-	return r.broker.Publish(topic, message)
+	return r.broker.Publish(topic, message, options...)
+}
+
+// PublishWithParams renders the topic pattern rawTopic with params, e.g. "bar/{bar_id}/state" with
+// {"bar_id": "42"}, and publishes the message to the resulting topic.
+func (r *Router) PublishWithParams(rawTopic string, params map[string]string, message Message, options ...PublishOption) error {
+	pattern, err := NewTopic(rawTopic)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := pattern.Render(params)
+	if err != nil {
+		return err
+	}
+
+	topic, err := NewTopic(rendered)
+	if err != nil {
+		return err
+	}
+
+	return r.broker.Publish(topic, message, options...)
 }
 
 type HandlerFunc func(Publisher, RoutedMessage) error
 
 type Middleware func(next HandlerFunc) HandlerFunc
 
-func identityMiddleware(h HandlerFunc) HandlerFunc {
+// ContextHandlerFunc is a HandlerFunc that also receives a context. The context is cancelled
+// when the context passed to Router.Shutdown expires.
+type ContextHandlerFunc func(context.Context, Publisher, RoutedMessage) error
+
+type ContextMiddleware func(next ContextHandlerFunc) ContextHandlerFunc
+
+// FromHandlerFunc adapts a HandlerFunc to a ContextHandlerFunc that ignores the context.
+func FromHandlerFunc(handler HandlerFunc) ContextHandlerFunc {
+	return func(_ context.Context, publisher Publisher, message RoutedMessage) error {
+		return handler(publisher, message)
+	}
+}
+
+// FromMiddleware adapts a Middleware to a ContextMiddleware. The context received by the
+// returned middleware is forwarded to the next handler in the chain.
+func FromMiddleware(middleware Middleware) ContextMiddleware {
+	return func(next ContextHandlerFunc) ContextHandlerFunc {
+		return func(ctx context.Context, publisher Publisher, message RoutedMessage) error {
+			return middleware(func(publisher Publisher, message RoutedMessage) error {
+				return next(ctx, publisher, message)
+			})(publisher, message)
+		}
+	}
+}
+
+func identityMiddleware(h ContextHandlerFunc) ContextHandlerFunc {
 	return h
 }
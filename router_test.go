@@ -0,0 +1,582 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeTransport struct {
+	mu               sync.Mutex
+	subscriptions    map[string]chan RoutedMessage
+	published        []Message
+	publishedTopics  []string
+	publishOptions   []PublishOptions
+	subscribeOptions map[string]SubscribeOptions
+	stateHandlers    []ConnectionStateHandler
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{
+		subscriptions:    make(map[string]chan RoutedMessage),
+		subscribeOptions: make(map[string]SubscribeOptions),
+	}
+}
+
+func (f *fakeTransport) Connect() error {
+	return nil
+}
+
+func (f *fakeTransport) Disconnect() error {
+	return nil
+}
+
+func (f *fakeTransport) OnConnectionStateChange(handler ConnectionStateHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stateHandlers = append(f.stateHandlers, handler)
+}
+
+func (f *fakeTransport) changeState(event ConnectionEvent) {
+	f.mu.Lock()
+	handlers := slices.Clone(f.stateHandlers)
+	f.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+func (f *fakeTransport) Subscribe(topic *Topic, options ...SubscribeOption) (<-chan RoutedMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	opts, err := NewSubscribeOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	f.subscribeOptions[topic.Raw()] = opts
+
+	messageChan := make(chan RoutedMessage, 16)
+	f.subscriptions[topic.Raw()] = messageChan
+	return messageChan, nil
+}
+
+func (f *fakeTransport) Unsubscribe(topic *Topic) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.subscriptions, topic.Raw())
+	return nil
+}
+
+func (f *fakeTransport) subscribed(rawTopic string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.subscriptions[rawTopic]
+	return ok
+}
+
+func (f *fakeTransport) Publish(topic *Topic, message Message, options ...PublishOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	opts, err := NewPublishOptions(options...)
+	if err != nil {
+		return err
+	}
+
+	f.published = append(f.published, message)
+	f.publishedTopics = append(f.publishedTopics, topic.Raw())
+	f.publishOptions = append(f.publishOptions, opts)
+	return nil
+}
+
+func (f *fakeTransport) deliver(rawTopic string, fullName string, message Message) {
+	f.deliverWithParams(rawTopic, fullName, map[string]string{}, message)
+}
+
+func (f *fakeTransport) deliverWithParams(rawTopic string, fullName string, params map[string]string, message Message) {
+	f.mu.Lock()
+	messageChan := f.subscriptions[rawTopic]
+	f.mu.Unlock()
+
+	messageChan <- RoutedMessage{
+		Message: message,
+		Topic:   NewTopicMatch(fullName, params),
+	}
+}
+
+func newTestRouter(transport *fakeTransport, options ...OptionFunc) *Router {
+	options = append([]OptionFunc{WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))}, options...)
+	return NewRouter(NewBroker(transport, transport), options...)
+}
+
+func Test_Router_ContextCancelledOnShutdownTimeout(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	_ = r.AddContextSubscription("foo", func(ctx context.Context, _ Publisher, _ RoutedMessage) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	transport.deliver("foo", "foo", Message{})
+	<-started
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_ = r.Shutdown(shutdownCtx)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Handler context was not cancelled.")
+	}
+}
+
+func Test_Router_FromMiddleware(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	type key struct{}
+
+	handled := make(chan any, 1)
+	_ = r.AddContextSubscription("foo", func(ctx context.Context, _ Publisher, _ RoutedMessage) error {
+		handled <- ctx.Value(key{})
+		return nil
+	})
+
+	var calls int
+	_ = r.UseMiddleware(func(next HandlerFunc) HandlerFunc {
+		return func(publisher Publisher, message RoutedMessage) error {
+			calls++
+			return next(publisher, message)
+		}
+	})
+
+	_ = r.UseContextMiddleware(func(next ContextHandlerFunc) ContextHandlerFunc {
+		return func(ctx context.Context, publisher Publisher, message RoutedMessage) error {
+			return next(context.WithValue(ctx, key{}, "value"), publisher, message)
+		}
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	transport.deliver("foo", "foo", Message{})
+
+	select {
+	case value := <-handled:
+		if value != "value" {
+			t.Fatalf("Test failed! Expected context value: %v, got: %v", "value", value)
+		}
+		if calls != 1 {
+			t.Fatalf("Test failed! Expected middleware calls: %d, got: %d", 1, calls)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Message was not handled.")
+	}
+}
+
+func Test_Router_DispatchModes(t *testing.T) {
+	type testCase struct {
+		options        []SubscriptionOption
+		expectedActive int
+	}
+
+	tests := map[string]testCase{
+		"Ordered - default": {
+			expectedActive: 1,
+		},
+		"Ordered - ignores max concurrency": {
+			options:        []SubscriptionOption{WithMaxConcurrency(4)},
+			expectedActive: 1,
+		},
+		"Parallel - max concurrency": {
+			options:        []SubscriptionOption{WithDispatchMode(DispatchParallel), WithMaxConcurrency(3), WithBufferSize(8)},
+			expectedActive: 3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport := newFakeTransport()
+			r := newTestRouter(transport)
+
+			var mu sync.Mutex
+			var active, maxActive int
+			_ = r.AddSubscription("foo", func(_ Publisher, _ RoutedMessage) error {
+				mu.Lock()
+				active++
+				maxActive = max(maxActive, active)
+				mu.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			}, test.options...)
+
+			if err := r.Start(); err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			for range 6 {
+				transport.deliver("foo", "foo", Message{})
+			}
+
+			time.Sleep(100 * time.Millisecond)
+			_ = r.Shutdown(context.Background())
+
+			if maxActive != test.expectedActive {
+				t.Fatalf("Test failed! Expected concurrent handlers: %d, got: %d", test.expectedActive, maxActive)
+			}
+		})
+	}
+}
+
+func Test_Router_ShutdownWaitsForBufferedMessages(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var handled int
+	_ = r.AddSubscription("foo", func(_ Publisher, _ RoutedMessage) error {
+		<-release
+		mu.Lock()
+		handled++
+		mu.Unlock()
+		return nil
+	}, WithBufferSize(4))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	for range 3 {
+		transport.deliver("foo", "foo", Message{})
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	close(release)
+	_ = r.Shutdown(context.Background())
+
+	if handled != 3 {
+		t.Fatalf("Test failed! Expected handled messages: %d, got: %d", 3, handled)
+	}
+}
+
+func Test_Router_KeyedDispatch(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	var mu sync.Mutex
+	var active, maxActive int
+	received := map[string][]string{}
+	_ = r.AddSubscription("devices/{device_id}/state", func(_ Publisher, message RoutedMessage) error {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		deviceID := message.GetTopicParam("device_id")
+		received[deviceID] = append(received[deviceID], string(message.Payload))
+		mu.Unlock()
+		return nil
+	}, WithOrderingParam("device_id"), WithMaxConcurrency(8), WithBufferSize(16))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	devices := []string{"a", "b", "c", "d"}
+	for i := range 5 {
+		for _, device := range devices {
+			transport.deliverWithParams("devices/{device_id}/state", "devices/"+device+"/state",
+				map[string]string{"device_id": device}, Message{Payload: []byte{byte('0' + i)}})
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	_ = r.Shutdown(context.Background())
+
+	for _, device := range devices {
+		got := received[device]
+		expected := []string{"0", "1", "2", "3", "4"}
+		if !slices.Equal(got, expected) {
+			t.Fatalf("Test failed! Expected order for %s: %v, got: %v", device, expected, got)
+		}
+	}
+
+	if maxActive < 2 {
+		t.Fatalf("Test failed! Expected different keys to be handled concurrently, got max: %d", maxActive)
+	}
+}
+
+func Test_Router_DynamicSubscriptions(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	handled := make(chan struct{}, 1)
+	err := r.AddSubscription("foo", func(_ Publisher, _ RoutedMessage) error {
+		handled <- struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if !transport.subscribed("foo") {
+		t.Fatal("Test failed! Expected topic to be subscribed while running.")
+	}
+
+	transport.deliver("foo", "foo", Message{})
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Message was not handled.")
+	}
+
+	if err := r.RemoveSubscription("foo"); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if transport.subscribed("foo") {
+		t.Fatal("Test failed! Expected topic to be unsubscribed.")
+	}
+
+	if err := r.RemoveSubscription("foo"); err != ErrSubscriptionNotFound {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrSubscriptionNotFound, err)
+	}
+
+	if err := r.UseMiddleware(func(next HandlerFunc) HandlerFunc { return next }); err != ErrCannotAddMiddleware {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrCannotAddMiddleware, err)
+	}
+}
+
+func Test_Router_RouteMiddleware(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) ContextMiddleware {
+		return func(next ContextHandlerFunc) ContextHandlerFunc {
+			return func(ctx context.Context, publisher Publisher, message RoutedMessage) error {
+				mu.Lock()
+				calls = append(calls, name+":"+message.Topic.FullName())
+				mu.Unlock()
+				return next(ctx, publisher, message)
+			}
+		}
+	}
+
+	handled := make(chan struct{}, 2)
+	handler := func(_ Publisher, _ RoutedMessage) error {
+		handled <- struct{}{}
+		return nil
+	}
+
+	_ = r.UseContextMiddleware(record("router"))
+	_ = r.AddSubscription("foo", handler, WithContextMiddleware(record("first"), record("second")))
+	_ = r.AddSubscription("bar", handler)
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	transport.deliver("foo", "foo", Message{})
+	<-handled
+	transport.deliver("bar", "bar", Message{})
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Message was not handled.")
+	}
+
+	expected := []string{"router:foo", "first:foo", "second:foo", "router:bar"}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !slices.Equal(calls, expected) {
+		t.Fatalf("Test failed! Expected: %v, got: %v", expected, calls)
+	}
+}
+
+func Test_Router_RejectsUnsatisfiedParamConstraints(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	handled := make(chan string, 2)
+	_ = r.AddSubscription("devices/{device_id:int}", func(_ Publisher, message RoutedMessage) error {
+		handled <- message.GetTopicParam("device_id")
+		return nil
+	})
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	transport.deliverWithParams("devices/{device_id:int}", "devices/abc", map[string]string{"device_id": "abc"}, Message{})
+	transport.deliverWithParams("devices/{device_id:int}", "devices/42", map[string]string{"device_id": "42"}, Message{})
+
+	select {
+	case got := <-handled:
+		if got != "42" {
+			t.Fatalf("Test failed! Expected param: %s, got: %s", "42", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Test failed! Message was not handled.")
+	}
+}
+
+func Test_Router_PublishWithParams(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	if err := r.PublishWithParams("bar/{bar_id}/state", map[string]string{"bar_id": "42"}, Message{}); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	if transport.publishedTopics[0] != "bar/42/state" {
+		t.Fatalf("Test failed! Expected topic: %s, got: %s", "bar/42/state", transport.publishedTopics[0])
+	}
+
+	if err := r.PublishWithParams("bar/{bar_id}/state", nil, Message{}); !errors.Is(err, ErrMissingTopicParam) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrMissingTopicParam, err)
+	}
+}
+
+func Test_Router_ConnectionStateEvents(t *testing.T) {
+	transport := newFakeTransport()
+
+	var events []ConnectionEvent
+	r := newTestRouter(transport, WithConnectionStateHandler(func(event ConnectionEvent) {
+		events = append(events, event)
+	}))
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	lostErr := errors.New("connection reset")
+	transport.changeState(ConnectionEvent{State: ConnectionLost, Err: lostErr})
+	transport.changeState(ConnectionEvent{State: ConnectionReconnecting})
+	transport.changeState(ConnectionEvent{State: ConnectionConnected})
+
+	// The transport is both the subscriber and the publisher, so the router must be notified only once.
+	expected := []ConnectionEvent{
+		{State: ConnectionLost, Err: lostErr},
+		{State: ConnectionReconnecting},
+		{State: ConnectionConnected},
+	}
+	if !slices.Equal(events, expected) {
+		t.Fatalf("Test failed! Expected events: %v, got: %v", expected, events)
+	}
+
+	_ = r.Shutdown(context.Background())
+}
+
+func Test_Router_ConsumerGroup(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	handler := func(Publisher, RoutedMessage) error { return nil }
+	_ = r.AddSubscription("foo/{foo_id}/topic", handler, WithConsumerGroup("workers"))
+
+	if err := r.AddSubscription("bar", handler, WithConsumerGroup("a/b")); !errors.Is(err, ErrInvalidSubscribeOption) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidSubscribeOption, err)
+	}
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	transport.mu.Lock()
+	opts := transport.subscribeOptions["foo/{foo_id}/topic"]
+	transport.mu.Unlock()
+
+	if opts.Group != "workers" {
+		t.Fatalf("Test failed! Expected group: %s, got: %s", "workers", opts.Group)
+	}
+}
+
+func Test_Router_InvalidBufferSize(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	handler := func(Publisher, RoutedMessage) error { return nil }
+
+	if err := r.AddSubscription("foo", handler, WithBufferSize(-1)); !errors.Is(err, ErrInvalidSubscriptionOption) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidSubscriptionOption, err)
+	}
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+	defer r.Shutdown(context.Background())
+
+	if err := r.AddSubscription("bar", handler, WithBufferSize(-1)); !errors.Is(err, ErrInvalidSubscriptionOption) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidSubscriptionOption, err)
+	}
+
+	if transport.subscribed("foo") || transport.subscribed("bar") {
+		t.Fatal("Test failed! Expected no subscriptions")
+	}
+}
+
+func Test_Router_KeyedDispatchWithoutKey(t *testing.T) {
+	transport := newFakeTransport()
+	r := newTestRouter(transport)
+
+	handler := func(Publisher, RoutedMessage) error { return nil }
+
+	if err := r.AddSubscription("foo", handler, WithDispatchMode(DispatchKeyed)); !errors.Is(err, ErrInvalidSubscriptionOption) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidSubscriptionOption, err)
+	}
+
+	if err := r.AddSubscription("bar", handler, WithOrderingKey(nil)); !errors.Is(err, ErrInvalidSubscriptionOption) {
+		t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidSubscriptionOption, err)
+	}
+
+	if err := r.AddSubscription("baz", handler, WithDispatchMode(DispatchKeyed), WithOrderingParam("id")); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package beacon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidSubscribeOption = errors.New("subscribe option is invalid")
+)
+
+// SubscribeOptions are the options of a single subscribe, resolved from a list of SubscribeOption.
+type SubscribeOptions struct {
+	// Consumer group sharing the subscription. Each message is delivered to only one member of the group.
+	// Empty if the subscription is not shared.
+	Group string
+}
+
+type SubscribeOption func(*SubscribeOptions)
+
+// NewSubscribeOptions resolves a list of SubscribeOption, to be used by transports.
+func NewSubscribeOptions(options ...SubscribeOption) (SubscribeOptions, error) {
+	var opts SubscribeOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	// Group names become a topic level in MQTT shared subscriptions, e.g. $share/<group>/foo/+.
+	if strings.ContainsAny(opts.Group, "/+#") {
+		return SubscribeOptions{}, fmt.Errorf("%w: group %q can not contain '/', '+' or '#'", ErrInvalidSubscribeOption, opts.Group)
+	}
+
+	return opts, nil
+}
+
+// WithGroup shares the subscription among every subscriber of the same topic in group, so that each
+// message is delivered to only one of them.
+func WithGroup(group string) func(*SubscribeOptions) {
+	return func(o *SubscribeOptions) {
+		o.Group = group
+	}
+}
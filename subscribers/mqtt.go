@@ -1,104 +1,78 @@
 package subscribers
 
 import (
-	"regexp"
-	"strings"
-
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/pmoura-dev/beacon"
+	"github.com/pmoura-dev/beacon/internal/mqttconn"
+	"github.com/pmoura-dev/beacon/mqttclient"
 )
 
 type MQTTSubscriber struct {
-	client               mqtt.Client
-	qos                  byte
-	disconnectionTimeout uint // milliseconds
+	conn   *mqttconn.Conn
+	config mqttconn.Config
 }
 
 type MQTTSubscriberOption func(*MQTTSubscriber)
 
 func NewMQTTSubscriber(url string, options ...MQTTSubscriberOption) *MQTTSubscriber {
-	opts := mqtt.NewClientOptions().AddBroker(url)
-	opts.SetCleanSession(true)
-
 	subscriber := &MQTTSubscriber{
-		client:               mqtt.NewClient(opts),
-		qos:                  0,
-		disconnectionTimeout: 250,
+		config: mqttconn.Config{
+			QOS:                  0,
+			DisconnectionTimeout: 250,
+		},
 	}
 
 	for _, opt := range options {
 		opt(subscriber)
 	}
 
+	subscriber.conn = mqttconn.New(url, subscriber.config)
+
 	return subscriber
 }
 
+// WithClientOptions configures the underlying MQTT client, e.g. its TLS configuration or credentials.
+func WithClientOptions(options ...mqttclient.Option) func(*MQTTSubscriber) {
+	return func(b *MQTTSubscriber) {
+		b.config.ClientOptions = append(b.config.ClientOptions, options...)
+	}
+}
+
 func WithQOS(qos byte) func(*MQTTSubscriber) {
 	return func(b *MQTTSubscriber) {
-		b.qos = qos
+		b.config.QOS = qos
 	}
 }
 
 func WithDisconnectionTimeout(timeout uint) func(*MQTTSubscriber) {
 	return func(b *MQTTSubscriber) {
-		b.disconnectionTimeout = timeout
+		b.config.DisconnectionTimeout = timeout
 	}
 }
 
-func (b *MQTTSubscriber) Connect() error {
-	if token := b.client.Connect(); token.Wait() && token.Error() != nil {
-		return token.Error()
+// WithEnvelope unwraps payloads produced by beacon.MarshalEnvelope, restoring the message headers.
+// Payloads that are not envelopes are delivered as they are.
+func WithEnvelope() func(*MQTTSubscriber) {
+	return func(b *MQTTSubscriber) {
+		b.config.Envelope = true
 	}
+}
 
-	return nil
+func (b *MQTTSubscriber) Connect() error {
+	return b.conn.Connect()
 }
 
 func (b *MQTTSubscriber) Disconnect() error {
-	b.client.Disconnect(b.disconnectionTimeout)
-	return nil
+	return b.conn.Disconnect()
 }
 
-func (b *MQTTSubscriber) Subscribe(topic *beacon.Topic) (<-chan beacon.RoutedMessage, error) {
-	messageChan := make(chan beacon.RoutedMessage)
-
-	mqttTopic := toMQTTTopic(topic.Raw())
-	token := b.client.Subscribe(mqttTopic, b.qos, func(c mqtt.Client, m mqtt.Message) {
-		topicMatch := extractParamsFromMQTTTopic(topic, m.Topic())
-		messageChan <- beacon.RoutedMessage{
-			Message: beacon.Message{
-				Payload: m.Payload(),
-			},
-			Topic: topicMatch,
-		}
-	})
-	if token.Wait() && token.Error() != nil {
-		return nil, token.Error()
-	}
-	return messageChan, nil
+func (b *MQTTSubscriber) OnConnectionStateChange(handler beacon.ConnectionStateHandler) {
+	b.conn.OnConnectionStateChange(handler)
 }
 
-func toMQTTTopic(topic string) string {
-	// Replace named wildcards {param} with "+"
-	mqttTopic := regexp.MustCompile(`\{[^}]+\}`).ReplaceAllString(topic, "+")
-
-	// Replace * with "#"
-	mqttTopic = strings.ReplaceAll(mqttTopic, "*", "#")
-
-	return mqttTopic
+func (b *MQTTSubscriber) Subscribe(topic *beacon.Topic, options ...beacon.SubscribeOption) (<-chan beacon.RoutedMessage, error) {
+	return b.conn.Subscribe(topic, options...)
 }
 
-func extractParamsFromMQTTTopic(topic *beacon.Topic, mqttTopic string) *beacon.TopicMatch {
-	// {param} -> ([^/]+)
-	rePattern := regexp.MustCompile(`\{([^}]+)\}`).ReplaceAllString(topic.Raw(), `([^/]+)`)
-	rePattern = strings.ReplaceAll(rePattern, "*", ".*")
-
-	re := regexp.MustCompile("^" + rePattern + "$")
-
-	groups := re.FindStringSubmatch(mqttTopic)
-	params := map[string]string{}
-	for i, g := range groups[1:] {
-		params[topic.Params()[i]] = g
-	}
-
-	return beacon.NewTopicMatch(mqttTopic, params)
+func (b *MQTTSubscriber) Unsubscribe(topic *beacon.Topic) error {
+	return b.conn.Unsubscribe(topic)
 }
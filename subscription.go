@@ -0,0 +1,217 @@
+package beacon
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"slices"
+)
+
+var ErrInvalidSubscriptionOption = errors.New("subscription option is invalid")
+
+type DispatchMode int
+
+const (
+	// DispatchOrdered handles messages one at a time, in the order they were received.
+	DispatchOrdered DispatchMode = iota
+
+	// DispatchParallel handles messages concurrently, up to the subscription's max concurrency.
+	DispatchParallel
+
+	// DispatchKeyed handles messages with the same ordering key in order, while messages with
+	// different keys are handled concurrently, up to the subscription's max concurrency. It requires an
+	// ordering key, see WithOrderingKey and WithOrderingParam.
+	DispatchKeyed
+)
+
+// OrderingKeyFunc returns the key used to shard messages across workers when using DispatchKeyed.
+type OrderingKeyFunc func(RoutedMessage) string
+
+type subscription struct {
+	topic   *Topic
+	handler ContextHandlerFunc
+
+	dispatchMode   DispatchMode
+	maxConcurrency int
+	bufferSize     int
+	orderingKey    OrderingKeyFunc
+
+	// Overrides the router's retry policy when set.
+	retryPolicy *RetryPolicy
+
+	// Overrides the router's dead-letter topic when set.
+	deadLetterTopic DeadLetterTopicFunc
+
+	// Middleware that only wraps this subscription's handler, inside the router's middleware.
+	middleware []ContextMiddleware
+
+	// Consumer group the subscription is shared with, if any.
+	group string
+
+	// Channel closed when the subscription is removed from a running router.
+	stop chan struct{}
+}
+
+type SubscriptionOption func(*subscription)
+
+func newSubscription(topic *Topic, handler ContextHandlerFunc, options ...SubscriptionOption) *subscription {
+	sub := &subscription{
+		topic:          topic,
+		handler:        handler,
+		dispatchMode:   DispatchOrdered,
+		maxConcurrency: runtime.NumCPU(),
+		bufferSize:     0,
+		stop:           make(chan struct{}),
+	}
+
+	for _, opt := range options {
+		opt(sub)
+	}
+
+	sub.handler = wrapHandler(sub.handler, sub.middleware)
+
+	return sub
+}
+
+func (s *subscription) validate() error {
+	if s.bufferSize < 0 {
+		return fmt.Errorf("%w: buffer size %d is negative", ErrInvalidSubscriptionOption, s.bufferSize)
+	}
+
+	// Without a key every message would go to the same worker, leaving the others idle.
+	if s.dispatchMode == DispatchKeyed && s.orderingKey == nil {
+		return fmt.Errorf("%w: keyed dispatch requires an ordering key", ErrInvalidSubscriptionOption)
+	}
+
+	return nil
+}
+
+func wrapHandler(handler ContextHandlerFunc, middleware []ContextMiddleware) ContextHandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler
+}
+
+// extractMiddleware wraps handler with the middleware set in options and returns options extended so that
+// the middleware is not applied again when the subscription is created.
+func extractMiddleware(handler ContextHandlerFunc, options []SubscriptionOption) (ContextHandlerFunc, []SubscriptionOption) {
+	sub := &subscription{}
+	for _, opt := range options {
+		opt(sub)
+	}
+
+	if len(sub.middleware) == 0 {
+		return handler, options
+	}
+
+	withoutMiddleware := func(s *subscription) {
+		s.middleware = nil
+	}
+
+	return wrapHandler(handler, sub.middleware), append(slices.Clip(options), withoutMiddleware)
+}
+
+func WithDispatchMode(mode DispatchMode) SubscriptionOption {
+	return func(s *subscription) {
+		s.dispatchMode = mode
+	}
+}
+
+// WithMaxConcurrency sets the number of handlers that may run at the same time when using DispatchParallel.
+func WithMaxConcurrency(n int) SubscriptionOption {
+	return func(s *subscription) {
+		s.maxConcurrency = n
+	}
+}
+
+// WithBufferSize sets how many received messages may wait for a free handler before the
+// subscription stops reading from the subscriber.
+func WithBufferSize(size int) SubscriptionOption {
+	return func(s *subscription) {
+		s.bufferSize = size
+	}
+}
+
+// WithOrderingKey dispatches messages using DispatchKeyed, deriving the ordering key with keyFunc.
+func WithOrderingKey(keyFunc OrderingKeyFunc) SubscriptionOption {
+	return func(s *subscription) {
+		s.dispatchMode = DispatchKeyed
+		s.orderingKey = keyFunc
+	}
+}
+
+// WithOrderingParam dispatches messages using DispatchKeyed, using the value of a topic param as the ordering key.
+func WithOrderingParam(param string) SubscriptionOption {
+	return WithOrderingKey(func(message RoutedMessage) string {
+		return message.GetTopicParam(param)
+	})
+}
+
+// WithSubscriptionRetryPolicy overrides the router's retry policy for this subscription.
+func WithSubscriptionRetryPolicy(policy RetryPolicy) SubscriptionOption {
+	return func(s *subscription) {
+		s.retryPolicy = &policy
+	}
+}
+
+// WithSubscriptionDeadLetter overrides the router's dead-letter topic for this subscription.
+func WithSubscriptionDeadLetter(topicFunc DeadLetterTopicFunc) SubscriptionOption {
+	return func(s *subscription) {
+		s.deadLetterTopic = topicFunc
+	}
+}
+
+// WithMiddleware wraps only this subscription's handler with the given middleware. The first middleware is the outermost.
+func WithMiddleware(middleware ...Middleware) SubscriptionOption {
+	return func(s *subscription) {
+		for _, m := range middleware {
+			s.middleware = append(s.middleware, FromMiddleware(m))
+		}
+	}
+}
+
+// WithContextMiddleware wraps only this subscription's handler with the given middleware. The first middleware is the outermost.
+func WithContextMiddleware(middleware ...ContextMiddleware) SubscriptionOption {
+	return func(s *subscription) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// WithConsumerGroup shares the subscription with every replica subscribing to the same topic in group,
+// so that each message is handled by only one of them. It maps to $share/<group>/... on MQTT.
+func WithConsumerGroup(group string) SubscriptionOption {
+	return func(s *subscription) {
+		s.group = group
+	}
+}
+
+func (s *subscription) workers() int {
+	if s.dispatchMode == DispatchOrdered || s.maxConcurrency < 1 {
+		return 1
+	}
+
+	return s.maxConcurrency
+}
+
+// subscribeOptions returns the options passed to the subscriber when subscribing to the topic.
+func (s *subscription) subscribeOptions() []SubscribeOption {
+	if s.group == "" {
+		return nil
+	}
+
+	return []SubscribeOption{WithGroup(s.group)}
+}
+
+// shard returns the index of the worker responsible for the message's ordering key.
+func (s *subscription) shard(message RoutedMessage, workers int) int {
+	if s.orderingKey == nil {
+		return 0
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s.orderingKey(message)))
+	return int(h.Sum32() % uint32(workers))
+}
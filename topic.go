@@ -2,7 +2,9 @@ package beacon
 
 import (
 	"errors"
+	"fmt"
 	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -10,18 +12,36 @@ var (
 	ErrEmptySingleLevelWildcard          = errors.New("single level wildcard is empty")
 	ErrDuplicatedSingleLevelWildcard     = errors.New("single level wildcard is duplicated")
 	ErrInvalidMultiLevelWildcardPosition = errors.New("multi-level wildcard '*' must be the last level")
+	ErrInvalidTailWildcardPosition       = errors.New("named multi-level wildcard '{param*}' must be the last level")
+	ErrInvalidParamConstraint            = errors.New("single level wildcard constraint is invalid")
+	ErrParamConstraintNotSatisfied       = errors.New("topic param does not satisfy its constraint")
+	ErrMissingTopicParam                 = errors.New("topic param is missing")
+	ErrUnexpectedTopicParam              = errors.New("topic param is not defined in the topic")
+	ErrInvalidTopicParamValue            = errors.New("topic param value is not a valid topic segment")
+	ErrWildcardTopic                     = errors.New("topic contains wildcards")
 )
 
 type Topic struct {
 	raw      string
 	segments []string
 	params   []string
+
+	// Constraints of typed params, e.g. {id:int}, indexed by param name. Nil if no param is typed.
+	constraints map[string]paramConstraint
+
+	// Name of the param that captures the remaining segments, e.g. path in files/{path*}.
+	tailParam string
 }
 
 func NewTopic(raw string) (*Topic, error) {
-	segments := strings.Split(raw, "/")
+	segments, err := splitSegments(raw)
+	if err != nil {
+		return nil, err
+	}
 
 	var params []string
+	var constraints map[string]paramConstraint
+	var tailParam string
 	for i, s := range segments {
 
 		if strings.Trim(s, " ") == "*" && i != len(segments)-1 {
@@ -29,23 +49,56 @@ func NewTopic(raw string) (*Topic, error) {
 		}
 
 		if isWildcard(s) {
-			param := strings.Trim(s[1:len(s)-1], " ")
+			param, rawConstraint, hasConstraint := strings.Cut(s[1:len(s)-1], ":")
+
+			param = strings.Trim(param, " ")
 			if param == "" {
 				return nil, ErrEmptySingleLevelWildcard
 			}
 
+			if strings.HasSuffix(param, "*") {
+				param = strings.Trim(strings.TrimSuffix(param, "*"), " ")
+				if param == "" {
+					return nil, ErrEmptySingleLevelWildcard
+				}
+
+				if i != len(segments)-1 {
+					return nil, ErrInvalidTailWildcardPosition
+				}
+
+				if hasConstraint {
+					return nil, fmt.Errorf("%w: named multi-level wildcards can not be typed", ErrInvalidParamConstraint)
+				}
+
+				tailParam = param
+			}
+
 			if slices.Contains(params, param) {
 				return nil, ErrDuplicatedSingleLevelWildcard
 			}
 
+			if hasConstraint {
+				constraint, err := parseParamConstraint(rawConstraint)
+				if err != nil {
+					return nil, err
+				}
+
+				if constraints == nil {
+					constraints = make(map[string]paramConstraint)
+				}
+				constraints[param] = constraint
+			}
+
 			params = append(params, param)
 		}
 	}
 
 	return &Topic{
-		raw:      raw,
-		segments: segments,
-		params:   params,
+		raw:         raw,
+		segments:    segments,
+		params:      params,
+		constraints: constraints,
+		tailParam:   tailParam,
 	}, nil
 }
 
@@ -61,6 +114,135 @@ func (t *Topic) Params() []string {
 	return t.params
 }
 
+// TailParam returns the name of the named multi-level wildcard, which is always the last segment,
+// or an empty string if the topic does not have one.
+func (t *Topic) TailParam() string {
+	return t.tailParam
+}
+
+// HasWildcards reports whether the topic contains any wildcard, in which case it can be subscribed to
+// but not published to. Levels that are MQTT wildcards, '+' or '#', also count as wildcards, as they
+// would otherwise be sent as is to MQTT servers.
+func (t *Topic) HasWildcards() bool {
+	if len(t.params) > 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(t.segments, func(s string) bool {
+		s = strings.Trim(s, " ")
+		return s == "*" || s == "+" || s == "#"
+	})
+}
+
+// Render builds a concrete topic by replacing every named wildcard with the value of its param.
+// Every param must be given a value that is a valid topic segment and satisfies its constraint, if any.
+// Topics with an unnamed multi-level wildcard '*' can not be rendered.
+func (t *Topic) Render(params map[string]string) (string, error) {
+	for param := range params {
+		if !slices.Contains(t.params, param) {
+			return "", fmt.Errorf("%w: %s", ErrUnexpectedTopicParam, param)
+		}
+	}
+
+	segments := make([]string, len(t.segments))
+	paramIndex := 0
+	for i, s := range t.segments {
+		if strings.Trim(s, " ") == "*" {
+			return "", fmt.Errorf("%w: '*' can not be rendered", ErrWildcardTopic)
+		}
+
+		if !isWildcard(s) {
+			segments[i] = s
+			continue
+		}
+
+		param := t.params[paramIndex]
+		paramIndex++
+
+		value, ok := params[param]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingTopicParam, param)
+		}
+
+		levels := []string{value}
+		if param == t.tailParam {
+			levels = strings.Split(value, "/")
+		}
+
+		for _, level := range levels {
+			if !isValidSegment(level) {
+				return "", fmt.Errorf("%w: %s=%q", ErrInvalidTopicParamValue, param, value)
+			}
+		}
+
+		if constraint, ok := t.constraints[param]; ok && !constraint.check(value) {
+			return "", fmt.Errorf("%w: %s=%q is not %s", ErrParamConstraintNotSatisfied, param, value, constraint.name)
+		}
+
+		segments[i] = value
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// Match matches a concrete topic against the topic, extracting the values of its params. A single level
+// wildcard matches exactly one level, while multi-level wildcards match the remaining levels, if any.
+// Param constraints are not checked, see Validate.
+func (t *Topic) Match(concrete string) (*TopicMatch, bool) {
+	params := make(map[string]string, len(t.params))
+
+	// Levels of the concrete topic are consumed one at a time, without splitting it upfront.
+	rest, more := concrete, true
+	paramIndex := 0
+	for i, s := range t.segments {
+		kind := t.segmentKind(i)
+
+		if kind == segmentMultiLevel || kind == segmentTail {
+			if kind == segmentTail {
+				params[t.tailParam] = ""
+				if more {
+					params[t.tailParam] = rest
+				}
+			}
+			return NewTopicMatch(concrete, params), true
+		}
+
+		if !more {
+			return nil, false
+		}
+
+		var level string
+		level, rest, more = strings.Cut(rest, "/")
+
+		switch kind {
+		case segmentParam:
+			params[t.params[paramIndex]] = level
+			paramIndex++
+		case segmentLiteral:
+			if level != s {
+				return nil, false
+			}
+		}
+	}
+
+	if more {
+		return nil, false
+	}
+
+	return NewTopicMatch(concrete, params), true
+}
+
+// Validate checks the params of a match against the constraints of the topic's typed params.
+func (t *Topic) Validate(match *TopicMatch) error {
+	for param, constraint := range t.constraints {
+		if value := match.Params()[param]; !constraint.check(value) {
+			return fmt.Errorf("%w: %s=%q is not %s", ErrParamConstraintNotSatisfied, param, value, constraint.name)
+		}
+	}
+
+	return nil
+}
+
 type TopicMatch struct {
 	fullName string
 	params   map[string]string
@@ -81,6 +263,70 @@ func (m *TopicMatch) Params() map[string]string {
 	return m.params
 }
 
+// ParamInt returns the value of a param parsed as an integer.
+func (m *TopicMatch) ParamInt(param string) (int, error) {
+	return strconv.Atoi(m.params[param])
+}
+
+type segmentKind int
+
+const (
+	segmentLiteral segmentKind = iota
+	segmentParam
+	segmentMultiLevel
+	segmentTail
+)
+
+func (t *Topic) segmentKind(i int) segmentKind {
+	s := t.segments[i]
+
+	switch {
+	case i == len(t.segments)-1 && t.tailParam != "":
+		return segmentTail
+	case strings.Trim(s, " ") == "*":
+		return segmentMultiLevel
+	case isWildcard(s):
+		return segmentParam
+	}
+
+	return segmentLiteral
+}
+
+// splitSegments splits a raw topic into its levels. Separators inside braces belong to the param
+// constraint, e.g. the regex of {id:regex([^/]+)}, so braces are matched before splitting.
+func splitSegments(raw string) ([]string, error) {
+	var segments []string
+	depth, start := 0, 0
+	for i, c := range raw {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return nil, fmt.Errorf("%w: unbalanced '}' in %q", ErrInvalidParamConstraint, raw)
+			}
+			depth--
+		case '/':
+			if depth == 0 {
+				segments = append(segments, raw[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced '{' in %q", ErrInvalidParamConstraint, raw)
+	}
+
+	return append(segments, raw[start:]), nil
+}
+
 func isWildcard(s string) bool {
 	return strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}")
 }
+
+// isValidSegment reports whether a param value can be used as a concrete topic segment, i.e. it is not
+// empty and can not be mistaken for a level separator or a wildcard.
+func isValidSegment(s string) bool {
+	return s != "" && strings.Trim(s, " ") != "*" && !strings.ContainsAny(s, "/+#{}")
+}
@@ -1,6 +1,7 @@
 package beacon
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -90,6 +91,15 @@ func Test_NewTopic(t *testing.T) {
 				params:   []string{"foo_id"},
 			},
 		},
+		"Named multi level wildcard": {
+			raw: "files/{foo_id}/{ path* }",
+			expected: &Topic{
+				raw:       "files/{foo_id}/{ path* }",
+				segments:  []string{"files", "{foo_id}", "{ path* }"},
+				params:    []string{"foo_id", "path"},
+				tailParam: "path",
+			},
+		},
 		"Error - Empty single level wildcard": {
 			raw:         "foo/{}/bar",
 			wantErr:     true,
@@ -105,6 +115,21 @@ func Test_NewTopic(t *testing.T) {
 			wantErr:     true,
 			expectedErr: ErrInvalidMultiLevelWildcardPosition,
 		},
+		"Error - Invalid named multi-level wildcard position": {
+			raw:         "files/{path*}/bar",
+			wantErr:     true,
+			expectedErr: ErrInvalidTailWildcardPosition,
+		},
+		"Error - Empty named multi-level wildcard": {
+			raw:         "files/{*}",
+			wantErr:     true,
+			expectedErr: ErrEmptySingleLevelWildcard,
+		},
+		"Error - Duplicated named multi-level wildcard": {
+			raw:         "files/{path}/{path*}",
+			wantErr:     true,
+			expectedErr: ErrDuplicatedSingleLevelWildcard,
+		},
 	}
 
 	for name, test := range tests {
@@ -130,3 +155,364 @@ func Test_NewTopic(t *testing.T) {
 		})
 	}
 }
+
+func Test_Topic_Validate(t *testing.T) {
+	type testCase struct {
+		raw     string
+		params  map[string]string
+		wantErr bool
+	}
+
+	tests := map[string]testCase{
+		"Untyped param": {
+			raw:    "foo/{foo_id}",
+			params: map[string]string{"foo_id": "anything"},
+		},
+		"Int - valid": {
+			raw:    "foo/{foo_id:int}",
+			params: map[string]string{"foo_id": "-42"},
+		},
+		"Int - invalid": {
+			raw:     "foo/{foo_id:int}",
+			params:  map[string]string{"foo_id": "abc"},
+			wantErr: true,
+		},
+		"UUID - valid": {
+			raw:    "foo/{foo_id:uuid}",
+			params: map[string]string{"foo_id": "123e4567-e89b-12d3-a456-426614174000"},
+		},
+		"UUID - invalid": {
+			raw:     "foo/{foo_id:uuid}",
+			params:  map[string]string{"foo_id": "123e4567"},
+			wantErr: true,
+		},
+		"Regex - valid": {
+			raw:    "foo/{foo_id:regex([a-z]+[0-9])}",
+			params: map[string]string{"foo_id": "abc1"},
+		},
+		"Regex - level separator": {
+			raw:    "dev/{dev_id:regex([^/]+)}/state",
+			params: map[string]string{"dev_id": "lamp"},
+		},
+		"Regex - level separator invalid": {
+			raw:     "dev/{dev_id:regex([^/]+)}/state",
+			params:  map[string]string{"dev_id": ""},
+			wantErr: true,
+		},
+		"Regex - anchored": {
+			raw:     "foo/{foo_id:regex([a-z]+)}",
+			params:  map[string]string{"foo_id": "abc1"},
+			wantErr: true,
+		},
+		"Enum - valid": {
+			raw:    "foo/{color:enum(red|green)}",
+			params: map[string]string{"color": "green"},
+		},
+		"Enum - invalid": {
+			raw:     "foo/{color:enum(red|green)}",
+			params:  map[string]string{"color": "blue"},
+			wantErr: true,
+		},
+		"Multiple - one invalid": {
+			raw:     "foo/{foo_id:int}/{color:enum(red|green)}",
+			params:  map[string]string{"foo_id": "1", "color": "blue"},
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			topic, err := NewTopic(test.raw)
+			if err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			err = topic.Validate(NewTopicMatch("", test.params))
+			if test.wantErr != (err != nil) {
+				t.Fatalf("Test failed! Expected error: %v, got: %v", test.wantErr, err)
+			}
+
+			if err != nil && !errors.Is(err, ErrParamConstraintNotSatisfied) {
+				t.Fatalf("Test failed! Expected error: %v, got: %v", ErrParamConstraintNotSatisfied, err)
+			}
+		})
+	}
+}
+
+func Test_NewTopic_InvalidConstraint(t *testing.T) {
+	tests := map[string]string{
+		"Unknown constraint": "foo/{foo_id:float}",
+		"Invalid regex":      "foo/{foo_id:regex([a-z)}",
+		"Empty enum value":   "foo/{foo_id:enum(a||b)}",
+		"Unclosed brace":     "foo/{foo_id:regex([a-z]{3)}",
+		"Unopened brace":     "foo/foo_id}/bar",
+	}
+
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewTopic(raw); !errors.Is(err, ErrInvalidParamConstraint) {
+				t.Fatalf("Test failed! Expected error: %v, got: %v", ErrInvalidParamConstraint, err)
+			}
+		})
+	}
+}
+
+func Test_NewTopic_ConstraintWithLevelSeparator(t *testing.T) {
+	topic, err := NewTopic("dev/{dev_id:regex([^/]+)}/state")
+	if err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	expectedSegments := []string{"dev", "{dev_id:regex([^/]+)}", "state"}
+	if !reflect.DeepEqual(topic.Segments(), expectedSegments) {
+		t.Fatalf("Test failed! Expected segments: %v, got: %v", expectedSegments, topic.Segments())
+	}
+
+	match, ok := topic.Match("dev/lamp/state")
+	if !ok || match.Params()["dev_id"] != "lamp" {
+		t.Fatalf("Test failed! Expected dev_id: %s, got: %v", "lamp", match)
+	}
+
+	if err := topic.Validate(match); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+}
+
+func Test_TopicMatch_ParamInt(t *testing.T) {
+	match := NewTopicMatch("foo/42", map[string]string{"foo_id": "42", "name": "bar"})
+
+	if got, err := match.ParamInt("foo_id"); err != nil || got != 42 {
+		t.Fatalf("Test failed! Expected: %d, got: %d (%v)", 42, got, err)
+	}
+
+	if _, err := match.ParamInt("name"); err == nil {
+		t.Fatal("Test failed! Expected error for non-integer param.")
+	}
+}
+
+func Test_Topic_Render(t *testing.T) {
+	type testCase struct {
+		raw         string
+		params      map[string]string
+		expected    string
+		wantErr     bool
+		expectedErr error
+	}
+
+	tests := map[string]testCase{
+		"No params": {
+			raw:      "foo/bar",
+			expected: "foo/bar",
+		},
+		"Single level wildcards": {
+			raw:      "bar/{bar_id}/state/{kind}",
+			params:   map[string]string{"bar_id": "42", "kind": "full"},
+			expected: "bar/42/state/full",
+		},
+		"Typed single level wildcard": {
+			raw:      "bar/{bar_id:int}/state",
+			params:   map[string]string{"bar_id": "42"},
+			expected: "bar/42/state",
+		},
+		"Named multi level wildcard": {
+			raw:      "files/{path*}",
+			params:   map[string]string{"path": "a/b/c.txt"},
+			expected: "files/a/b/c.txt",
+		},
+		"Error - Missing param": {
+			raw:         "bar/{bar_id}/state",
+			params:      map[string]string{},
+			wantErr:     true,
+			expectedErr: ErrMissingTopicParam,
+		},
+		"Error - Unexpected param": {
+			raw:         "bar/{bar_id}/state",
+			params:      map[string]string{"bar_id": "42", "foo_id": "1"},
+			wantErr:     true,
+			expectedErr: ErrUnexpectedTopicParam,
+		},
+		"Error - Value with level separator": {
+			raw:         "bar/{bar_id}/state",
+			params:      map[string]string{"bar_id": "4/2"},
+			wantErr:     true,
+			expectedErr: ErrInvalidTopicParamValue,
+		},
+		"Error - Value with MQTT wildcard": {
+			raw:         "bar/{bar_id}/state",
+			params:      map[string]string{"bar_id": "#"},
+			wantErr:     true,
+			expectedErr: ErrInvalidTopicParamValue,
+		},
+		"Error - Empty value": {
+			raw:         "bar/{bar_id}/state",
+			params:      map[string]string{"bar_id": ""},
+			wantErr:     true,
+			expectedErr: ErrInvalidTopicParamValue,
+		},
+		"Error - Empty level in named multi level wildcard": {
+			raw:         "files/{path*}",
+			params:      map[string]string{"path": "a//b"},
+			wantErr:     true,
+			expectedErr: ErrInvalidTopicParamValue,
+		},
+		"Error - Constraint not satisfied": {
+			raw:         "bar/{bar_id:int}/state",
+			params:      map[string]string{"bar_id": "abc"},
+			wantErr:     true,
+			expectedErr: ErrParamConstraintNotSatisfied,
+		},
+		"Error - Multi level wildcard": {
+			raw:         "bar/*",
+			wantErr:     true,
+			expectedErr: ErrWildcardTopic,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			topic, err := NewTopic(test.raw)
+			if err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			got, err := topic.Render(test.params)
+
+			if test.wantErr {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Test failed! Expected error: %v, got: %v", test.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+
+			if got != test.expected {
+				t.Fatalf("Test failed! Expected: %s, got: %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func Test_Topic_HasWildcards(t *testing.T) {
+	tests := map[string]bool{
+		"foo/bar":        false,
+		"foo/{foo_id}":   true,
+		"foo/*":          true,
+		"files/{path*}":  true,
+		"foo/{id:int}/x": true,
+		"foo/+":          true,
+		"foo/#":          true,
+		"foo/+/bar":      true,
+	}
+
+	for raw, expected := range tests {
+		t.Run(raw, func(t *testing.T) {
+			topic, _ := NewTopic(raw)
+
+			if got := topic.HasWildcards(); got != expected {
+				t.Fatalf("Test failed! Expected: %v, got: %v", expected, got)
+			}
+		})
+	}
+}
+
+func Test_Topic_Match(t *testing.T) {
+	type testCase struct {
+		raw      string
+		concrete string
+		expected *TopicMatch
+	}
+
+	tests := map[string]testCase{
+		"Simple - match": {
+			raw:      "foo/bar",
+			concrete: "foo/bar",
+			expected: NewTopicMatch("foo/bar", map[string]string{}),
+		},
+		"Simple - no match": {
+			raw:      "foo/bar",
+			concrete: "foo/baz",
+		},
+		"Simple - longer concrete topic": {
+			raw:      "foo/bar",
+			concrete: "foo/bar/baz",
+		},
+		"Simple - shorter concrete topic": {
+			raw:      "foo/bar",
+			concrete: "foo",
+		},
+		"Single level wildcard - multiple": {
+			raw:      "foo/{foo_id}/bar/{bar_id}",
+			concrete: "foo/12345/bar/abcde",
+			expected: NewTopicMatch("foo/12345/bar/abcde", map[string]string{
+				"foo_id": "12345",
+				"bar_id": "abcde",
+			}),
+		},
+		"Single level wildcard - missing level": {
+			raw:      "foo/{foo_id}",
+			concrete: "foo",
+		},
+		"Single level wildcard - empty level": {
+			raw:      "foo/{foo_id}",
+			concrete: "foo/",
+			expected: NewTopicMatch("foo/", map[string]string{
+				"foo_id": "",
+			}),
+		},
+		"Multi level wildcard - root": {
+			raw:      "*",
+			concrete: "random/segment",
+			expected: NewTopicMatch("random/segment", map[string]string{}),
+		},
+		"Multi level wildcard - parent level": {
+			raw:      "foo/*",
+			concrete: "foo",
+			expected: NewTopicMatch("foo", map[string]string{}),
+		},
+		"Multi level wildcard - with single level wildcard before": {
+			raw:      "foo/{foo_id}/*",
+			concrete: "foo/12345/random/segment",
+			expected: NewTopicMatch("foo/12345/random/segment", map[string]string{
+				"foo_id": "12345",
+			}),
+		},
+		"Named multi level wildcard": {
+			raw:      "files/{foo_id}/{path*}",
+			concrete: "files/12345/a/b/c.txt",
+			expected: NewTopicMatch("files/12345/a/b/c.txt", map[string]string{
+				"foo_id": "12345",
+				"path":   "a/b/c.txt",
+			}),
+		},
+		"Named multi level wildcard - parent level": {
+			raw:      "files/{path*}",
+			concrete: "files",
+			expected: NewTopicMatch("files", map[string]string{
+				"path": "",
+			}),
+		},
+		"Named multi level wildcard - no match": {
+			raw:      "files/{path*}",
+			concrete: "dirs/a",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			topic, _ := NewTopic(test.raw)
+
+			got, ok := topic.Match(test.concrete)
+
+			if ok != (test.expected != nil) {
+				t.Fatalf("Test failed! Expected match: %v, got: %v", test.expected != nil, ok)
+			}
+
+			if ok && !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("Test failed! Expected: %v, got: %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package beacon
+
+import (
+	"strings"
+)
+
+// TopicTrie indexes topics by segment so that a concrete topic can be matched against all of them
+// in a single pass. It is not safe for concurrent use.
+type TopicTrie[V any] struct {
+	root *trieNode[V]
+}
+
+type TrieMatch[V any] struct {
+	Topic *Topic
+	Match *TopicMatch
+	Value V
+}
+
+type trieNode[V any] struct {
+	literals map[string]*trieNode[V]
+	param    *trieNode[V]
+
+	// Topics whose last segment is this node.
+	entries []trieEntry[V]
+
+	// Topics whose last segment is a multi-level wildcard following this node. They match any
+	// remaining levels, including none.
+	multiLevel []trieEntry[V]
+}
+
+type trieEntry[V any] struct {
+	topic *Topic
+	value V
+}
+
+func NewTopicTrie[V any]() *TopicTrie[V] {
+	return &TopicTrie[V]{
+		root: newTrieNode[V](),
+	}
+}
+
+func newTrieNode[V any]() *trieNode[V] {
+	return &trieNode[V]{
+		literals: make(map[string]*trieNode[V]),
+	}
+}
+
+// Insert adds a topic to the trie. The same topic can be inserted several times with different values.
+func (t *TopicTrie[V]) Insert(topic *Topic, value V) {
+	entry := trieEntry[V]{topic: topic, value: value}
+
+	node := t.root
+	for i, s := range topic.segments {
+		switch topic.segmentKind(i) {
+		case segmentMultiLevel, segmentTail:
+			node.multiLevel = append(node.multiLevel, entry)
+			return
+		case segmentParam:
+			if node.param == nil {
+				node.param = newTrieNode[V]()
+			}
+			node = node.param
+		case segmentLiteral:
+			child, ok := node.literals[s]
+			if !ok {
+				child = newTrieNode[V]()
+				node.literals[s] = child
+			}
+			node = child
+		}
+	}
+
+	node.entries = append(node.entries, entry)
+}
+
+// Remove removes the entries inserted with topic and returns their values. Topics are compared by
+// identity, so the entries of an equal topic inserted separately are kept.
+func (t *TopicTrie[V]) Remove(topic *Topic) []V {
+	var removed []V
+	remove := func(entries []trieEntry[V]) []trieEntry[V] {
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.topic == topic {
+				removed = append(removed, e.value)
+				continue
+			}
+			kept = append(kept, e)
+		}
+		return kept
+	}
+
+	node := t.root
+	for i, s := range topic.segments {
+		switch topic.segmentKind(i) {
+		case segmentMultiLevel, segmentTail:
+			node.multiLevel = remove(node.multiLevel)
+			return removed
+		case segmentParam:
+			node = node.param
+		case segmentLiteral:
+			node = node.literals[s]
+		}
+
+		if node == nil {
+			return nil
+		}
+	}
+
+	node.entries = remove(node.entries)
+	return removed
+}
+
+// Values returns the values of every entry in the trie.
+func (t *TopicTrie[V]) Values() []V {
+	var values []V
+	t.root.walk(func(e trieEntry[V]) {
+		values = append(values, e.value)
+	})
+	return values
+}
+
+// Match returns every entry whose topic matches the concrete topic, together with the extracted params.
+func (t *TopicTrie[V]) Match(concrete string) []TrieMatch[V] {
+	var matches []TrieMatch[V]
+	t.root.match(concrete, strings.Split(concrete, "/"), 0, nil, &matches)
+	return matches
+}
+
+func (n *trieNode[V]) match(concrete string, levels []string, depth int, captured []string, matches *[]TrieMatch[V]) {
+	for _, e := range n.multiLevel {
+		*matches = append(*matches, e.newMatch(concrete, captured, levels[depth:]))
+	}
+
+	if depth == len(levels) {
+		for _, e := range n.entries {
+			*matches = append(*matches, e.newMatch(concrete, captured, nil))
+		}
+		return
+	}
+
+	if child, ok := n.literals[levels[depth]]; ok {
+		child.match(concrete, levels, depth+1, captured, matches)
+	}
+
+	if n.param != nil {
+		// Clip captured so that sibling branches do not overwrite each other's values.
+		n.param.match(concrete, levels, depth+1, append(captured[:len(captured):len(captured)], levels[depth]), matches)
+	}
+}
+
+func (n *trieNode[V]) walk(f func(trieEntry[V])) {
+	for _, e := range n.entries {
+		f(e)
+	}
+
+	for _, e := range n.multiLevel {
+		f(e)
+	}
+
+	for _, child := range n.literals {
+		child.walk(f)
+	}
+
+	if n.param != nil {
+		n.param.walk(f)
+	}
+}
+
+// newMatch builds the match of the entry's topic from the values captured by its single level
+// wildcards, in order, and the levels left for its multi-level wildcard.
+func (e trieEntry[V]) newMatch(concrete string, captured []string, remaining []string) TrieMatch[V] {
+	params := make(map[string]string, len(e.topic.params))
+	for i, value := range captured {
+		params[e.topic.params[i]] = value
+	}
+
+	if e.topic.tailParam != "" {
+		params[e.topic.tailParam] = strings.Join(remaining, "/")
+	}
+
+	return TrieMatch[V]{
+		Topic: e.topic,
+		Match: NewTopicMatch(concrete, params),
+		Value: e.value,
+	}
+}
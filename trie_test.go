@@ -0,0 +1,99 @@
+package beacon
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func Test_TopicTrie_Match(t *testing.T) {
+	rawTopics := []string{
+		"foo/bar",
+		"foo/{foo_id}",
+		"foo/{foo_id}/bar/{bar_id}",
+		"foo/*",
+		"foo/{foo_id}/*",
+		"*",
+		"files/{path*}",
+		"files/{kind}/{path*}",
+		"{root}/bar",
+	}
+
+	trie := NewTopicTrie[string]()
+	var topics []*Topic
+	for _, raw := range rawTopics {
+		topic, _ := NewTopic(raw)
+		topics = append(topics, topic)
+		trie.Insert(topic, raw)
+	}
+
+	concreteTopics := []string{
+		"foo",
+		"foo/bar",
+		"foo/12345",
+		"foo/12345/bar/abcde",
+		"foo/12345/random/segment",
+		"files",
+		"files/a/b/c.txt",
+		"other/bar",
+		"",
+	}
+
+	// The trie must agree with matching every topic individually.
+	for _, concrete := range concreteTopics {
+		t.Run(concrete, func(t *testing.T) {
+			expected := map[string]*TopicMatch{}
+			for _, topic := range topics {
+				if match, ok := topic.Match(concrete); ok {
+					expected[topic.Raw()] = match
+				}
+			}
+
+			got := map[string]*TopicMatch{}
+			for _, m := range trie.Match(concrete) {
+				if m.Value != m.Topic.Raw() {
+					t.Fatalf("Test failed! Expected value: %s, got: %s", m.Topic.Raw(), m.Value)
+				}
+				got[m.Value] = m.Match
+			}
+
+			if !reflect.DeepEqual(expected, got) {
+				t.Fatalf("Test failed! Expected: %v, got: %v", expected, got)
+			}
+		})
+	}
+}
+
+func Test_TopicTrie_Remove(t *testing.T) {
+	trie := NewTopicTrie[int]()
+
+	foo, _ := NewTopic("foo/{foo_id}")
+	sameFoo, _ := NewTopic("foo/{foo_id}")
+	all, _ := NewTopic("foo/*")
+
+	trie.Insert(foo, 1)
+	trie.Insert(sameFoo, 2)
+	trie.Insert(all, 3)
+
+	// Only the entry inserted with the same topic is removed, not the one of an equal topic.
+	if removed := trie.Remove(foo); !slices.Equal(removed, []int{1}) {
+		t.Fatalf("Test failed! Expected removed: %v, got: %v", []int{1}, removed)
+	}
+
+	if removed := trie.Remove(foo); removed != nil {
+		t.Fatalf("Test failed! Expected nothing to be removed, got: %v", removed)
+	}
+
+	if removed := trie.Remove(sameFoo); !slices.Equal(removed, []int{2}) {
+		t.Fatalf("Test failed! Expected removed: %v, got: %v", []int{2}, removed)
+	}
+
+	matches := trie.Match("foo/1")
+	if len(matches) != 1 || matches[0].Value != 3 {
+		t.Fatalf("Test failed! Expected only the multi-level wildcard to match, got: %v", matches)
+	}
+
+	if values := trie.Values(); !slices.Equal(values, []int{3}) {
+		t.Fatalf("Test failed! Expected values: %v, got: %v", []int{3}, values)
+	}
+}
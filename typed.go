@@ -0,0 +1,57 @@
+package beacon
+
+import (
+	"context"
+)
+
+// TypedHandlerFunc handles messages whose payload was decoded into a value of type T.
+// The publisher and the original message can be retrieved from the context with
+// PublisherFromContext and MessageFromContext.
+type TypedHandlerFunc[T any] func(ctx context.Context, payload T, topic TopicMatch) error
+
+type publisherContextKey struct{}
+
+type messageContextKey struct{}
+
+// AddTypedSubscription adds a subscription whose payloads are decoded with the router's codec
+// before reaching the handler. Payloads that can not be decoded result in a *DecodeError.
+func AddTypedSubscription[T any](r *Router, rawTopic string, handler TypedHandlerFunc[T], options ...SubscriptionOption) error {
+	return r.AddContextSubscription(rawTopic, func(ctx context.Context, publisher Publisher, message RoutedMessage) error {
+		var payload T
+		if err := r.codec.Unmarshal(message.Payload, &payload); err != nil {
+			return &DecodeError{
+				Topic:       message.Topic.FullName(),
+				ContentType: r.codec.ContentType(),
+				Err:         err,
+			}
+		}
+
+		ctx = context.WithValue(ctx, publisherContextKey{}, publisher)
+		ctx = context.WithValue(ctx, messageContextKey{}, message)
+
+		return handler(ctx, payload, *message.Topic)
+	}, options...)
+}
+
+// PublishTyped encodes payload with codec and publishes it, setting the message's content type.
+func PublishTyped[T any](publisher Publisher, topic *Topic, payload T, codec Codec, options ...PublishOption) error {
+	data, err := codec.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return publisher.Publish(topic, Message{
+		ContentType: codec.ContentType(),
+		Payload:     data,
+	}, options...)
+}
+
+func PublisherFromContext(ctx context.Context) (Publisher, bool) {
+	publisher, ok := ctx.Value(publisherContextKey{}).(Publisher)
+	return publisher, ok
+}
+
+func MessageFromContext(ctx context.Context) (RoutedMessage, bool) {
+	message, ok := ctx.Value(messageContextKey{}).(RoutedMessage)
+	return message, ok
+}
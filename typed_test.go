@@ -0,0 +1,100 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testPayload struct {
+	Value int `json:"value"`
+}
+
+func Test_AddTypedSubscription(t *testing.T) {
+	type testCase struct {
+		payload       string
+		expected      testPayload
+		wantDecodeErr bool
+	}
+
+	tests := map[string]testCase{
+		"Valid payload": {
+			payload:  `{"value": 42}`,
+			expected: testPayload{Value: 42},
+		},
+		"Invalid payload": {
+			payload:       `{"value": "42"`,
+			wantDecodeErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport := newFakeTransport()
+			r := newTestRouter(transport)
+
+			handled := make(chan testPayload, 1)
+			_ = AddTypedSubscription(r, "foo/{foo_id}", func(ctx context.Context, payload testPayload, topic TopicMatch) error {
+				if _, ok := PublisherFromContext(ctx); !ok {
+					t.Errorf("Test failed! Expected publisher in context.")
+				}
+				if topic.Params()["foo_id"] != "1" {
+					t.Errorf("Test failed! Expected param: %s, got: %s", "1", topic.Params()["foo_id"])
+				}
+
+				handled <- payload
+				return nil
+			})
+
+			errs := make(chan error, 1)
+			_ = r.UseContextMiddleware(func(next ContextHandlerFunc) ContextHandlerFunc {
+				return func(ctx context.Context, publisher Publisher, message RoutedMessage) error {
+					err := next(ctx, publisher, message)
+					errs <- err
+					return err
+				}
+			})
+
+			if err := r.Start(); err != nil {
+				t.Fatalf("Test failed! Unexpected error: %v", err)
+			}
+			defer r.Shutdown(context.Background())
+
+			transport.deliverWithParams("foo/{foo_id}", "foo/1", map[string]string{"foo_id": "1"}, Message{Payload: []byte(test.payload)})
+
+			select {
+			case err := <-errs:
+				var decodeErr *DecodeError
+				if errors.As(err, &decodeErr) != test.wantDecodeErr {
+					t.Fatalf("Test failed! Expected decode error: %v, got: %v", test.wantDecodeErr, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Test failed! Message was not handled.")
+			}
+
+			if !test.wantDecodeErr {
+				if got := <-handled; got != test.expected {
+					t.Fatalf("Test failed! Expected: %v, got: %v", test.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func Test_PublishTyped(t *testing.T) {
+	transport := newFakeTransport()
+	topic, _ := NewTopic("foo")
+
+	if err := PublishTyped(transport, topic, testPayload{Value: 42}, JSONCodec{}); err != nil {
+		t.Fatalf("Test failed! Unexpected error: %v", err)
+	}
+
+	got := transport.published[0]
+	if got.ContentType != "application/json" {
+		t.Fatalf("Test failed! Expected content type: %s, got: %s", "application/json", got.ContentType)
+	}
+	if string(got.Payload) != `{"value":42}` {
+		t.Fatalf("Test failed! Expected payload: %s, got: %s", `{"value":42}`, got.Payload)
+	}
+}